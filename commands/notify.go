@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amitizle/muffin/internal/config"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// notifyCmd represents the notify command
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "notifiers commands",
+}
+
+// notifyTestCmd represents the notify test command
+var notifyTestCmd = &cobra.Command{
+	Use:   "test [notifier names...]",
+	Short: "send a test notification through the notifiers",
+	Long: `Send a test notification through all of the configured notifiers, or through the
+given ones, and report which of them failed. The test notification is a failure of a
+check named "` + notifiers.TestCheckName + `" followed by its recovery, both marked as a test.
+The exit code is non-zero if any of the notifiers failed.`,
+	Run: testNotifiers,
+}
+
+func init() {
+	notifyCmd.AddCommand(notifyTestCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
+
+func testNotifiers(cmd *cobra.Command, args []string) {
+	// the results are written to stdout, so logs go to stderr
+	log.Logger = log.Logger.Output(os.Stderr)
+
+	selected := cfg.Notifiers
+	if len(args) > 0 {
+		byName := map[string]*config.NotifierInstance{}
+		for _, cfgNotifier := range cfg.Notifiers {
+			byName[cfgNotifier.Name] = cfgNotifier
+		}
+		selected = []*config.NotifierInstance{}
+		for _, name := range args {
+			cfgNotifier, ok := byName[name]
+			if !ok {
+				exitWithError(fmt.Errorf("notifier %q does not exist", name))
+			}
+			selected = append(selected, cfgNotifier)
+		}
+	}
+	if len(selected) == 0 {
+		exitWithError(fmt.Errorf("no notifiers are configured"))
+	}
+
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tRESULT\tERROR")
+	for _, cfgNotifier := range selected {
+		result, errMessage := "ok", ""
+		if err := testNotifier(cfgNotifier); err != nil {
+			failed = true
+			result, errMessage = "failed", err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cfgNotifier.Name, cfgNotifier.Type, result, errMessage)
+	}
+	w.Flush()
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// testNotifier initializes the notifier and notifies it with the test events
+func testNotifier(cfgNotifier *config.NotifierInstance) error {
+	if err := initializeNotifier(cfgNotifier); err != nil {
+		return err
+	}
+	for _, event := range notifiers.TestEvents(time.Now()) {
+		if err := cfgNotifier.Notifier.Notify(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
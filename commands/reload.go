@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/amitizle/muffin/internal/config"
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// reloadDebounce is how long to wait after a change of the config file before reloading it,
+// editors and config management tools usually change the file more than once
+const reloadDebounce = 500 * time.Millisecond
+
+// watchConfig reloads the checks and notifiers on SIGHUP and when the config file changes,
+// it blocks until the context is cancelled. Once "muffin start" is running `cfg` is only
+// read and replaced here.
+func watchConfig(ctx context.Context, mon *monitor.Monitor) {
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	// SIGHUP would terminate muffin once it's not handled, so it's ignored after
+	// the watcher stops rather than reset
+	defer signal.Ignore(syscall.SIGHUP)
+	changes := make(chan struct{}, 1)
+	if file := viper.ConfigFileUsed(); file != "" {
+		if err := watchConfigFile(ctx, file, changes); err != nil {
+			log.Error().Err(err).Msg("failed watching config file, reload with SIGHUP")
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloads:
+			log.Info().Msg("received SIGHUP, reloading config")
+		case <-changes:
+			log.Info().Msg("config file changed, reloading config")
+		}
+		if err := reloadConfig(mon); err != nil {
+			log.Error().Err(err).Msg("failed reloading config, keeping the current config")
+		}
+	}
+}
+
+// watchConfigFile sends to `changes` when the config file changes. The file's directory is
+// watched and not the file itself, so files that are replaced (i.e by editors or by kubernetes
+// when a ConfigMap changes) are still watched. The file is watched until the context is cancelled.
+func watchConfigFile(ctx context.Context, file string, changes chan<- struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	file = filepath.Clean(file)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != file && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("config file watcher failed")
+			case <-debounce:
+				debounce = nil
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// checksDiff is the difference between the checks of the current and the new config
+type checksDiff struct {
+	added     []*config.CheckInstance
+	changed   []*config.CheckInstance
+	unchanged []*config.CheckInstance
+	removed   []string
+}
+
+// diffChecks compares the checks of the current config to the checks of the new
+// config, which has unique check names. Checks that did not change keep their initialized check.
+func diffChecks(oldChecks, newChecks []*config.CheckInstance) *checksDiff {
+	diff := &checksDiff{
+		added:     []*config.CheckInstance{},
+		changed:   []*config.CheckInstance{},
+		unchanged: []*config.CheckInstance{},
+		removed:   []string{},
+	}
+	byName := map[string]*config.CheckInstance{}
+	for _, cfgCheck := range oldChecks {
+		byName[cfgCheck.Name] = cfgCheck
+	}
+	names := map[string]bool{}
+	for _, cfgCheck := range newChecks {
+		names[cfgCheck.Name] = true
+		old, exists := byName[cfgCheck.Name]
+		switch {
+		case !exists:
+			diff.added = append(diff.added, cfgCheck)
+		case old.Equal(cfgCheck):
+			cfgCheck.Check = old.Check
+			diff.unchanged = append(diff.unchanged, cfgCheck)
+		default:
+			diff.changed = append(diff.changed, cfgCheck)
+		}
+	}
+	for _, cfgCheck := range oldChecks {
+		if !names[cfgCheck.Name] {
+			diff.removed = append(diff.removed, cfgCheck.Name)
+		}
+	}
+	return diff
+}
+
+// reuseNotifiers sets the initialized notifier of the new config's notifiers that did
+// not change, it returns the notifiers that are new or changed and have to be initialized
+func reuseNotifiers(oldNotifiers, newNotifiers []*config.NotifierInstance) []*config.NotifierInstance {
+	byName := map[string]*config.NotifierInstance{}
+	for _, cfgNotifier := range oldNotifiers {
+		byName[cfgNotifier.Name] = cfgNotifier
+	}
+	uninitialized := []*config.NotifierInstance{}
+	for _, cfgNotifier := range newNotifiers {
+		if old, ok := byName[cfgNotifier.Name]; ok && old.Equal(cfgNotifier) {
+			cfgNotifier.Notifier = old.Notifier
+			continue
+		}
+		uninitialized = append(uninitialized, cfgNotifier)
+	}
+	return uninitialized
+}
+
+// reloadConfig reads the config file and applies the changes to the checks and notifiers.
+// Checks and notifiers that did not change are kept as is, changed checks are rescheduled
+// and keep their state. If anything in the new config is invalid nothing is changed.
+// Only checks and notifiers are reloaded, other changes require a restart.
+func reloadConfig(mon *monitor.Monitor) error {
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	newCfg := config.New()
+	if err := viper.Unmarshal(newCfg); err != nil {
+		return err
+	}
+	if errs := newCfg.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			log.Error().Err(err).Msg("invalid config")
+		}
+		return fmt.Errorf("the new config has %d error(s)", len(errs))
+	}
+	if err := newCfg.ResolveNotifiers(); err != nil {
+		return err
+	}
+
+	for _, cfgNotifier := range reuseNotifiers(cfg.Notifiers, newCfg.Notifiers) {
+		if err := initializeNotifier(cfgNotifier); err != nil {
+			return fmt.Errorf("notifier %q: %w", cfgNotifier.Name, err)
+		}
+	}
+	diff := diffChecks(cfg.Checks, newCfg.Checks)
+	added, err := newMonitorChecks(diff.added)
+	if err != nil {
+		return err
+	}
+	changed, err := newMonitorChecks(diff.changed)
+	if err != nil {
+		return err
+	}
+
+	// the new config is valid, apply it. Adding a check can still fail (restoring its state
+	// from the history), so checks are added first and the ones that were already added are
+	// removed if one fails. Removing and updating checks and setting their notifiers can't
+	// fail from here on, they only fail for checks the monitor doesn't have, and the
+	// monitor's checks are the current config's checks as both are only changed here.
+	for i, check := range added {
+		if err := mon.Add(check); err != nil {
+			for _, check := range added[:i] {
+				mon.Remove(check.Name)
+			}
+			return err
+		}
+	}
+	for _, name := range diff.removed {
+		if err := mon.Remove(name); err != nil {
+			log.Error().Err(err).Msg("failed removing check")
+		}
+	}
+	for _, check := range changed {
+		if err := mon.Update(check); err != nil {
+			log.Error().Err(err).Msg("failed updating check")
+		}
+	}
+	for _, cfgCheck := range diff.unchanged {
+		if err := mon.SetNotifiers(cfgCheck.Name, routedNotifiers(cfgCheck)); err != nil {
+			log.Error().Err(err).Msg("failed setting check notifiers")
+		}
+	}
+	newCfg.Log, newCfg.Server, newCfg.StatusPage, newCfg.History = cfg.Log, cfg.Server, cfg.StatusPage, cfg.History
+	newCfg.ShutdownTimeout = cfg.ShutdownTimeout
+	cfg = newCfg
+
+	log.Info().
+		Int("added", len(added)).
+		Int("changed", len(changed)).
+		Int("removed", len(diff.removed)).
+		Int("unchanged", len(diff.unchanged)).
+		Msg("reloaded config")
+	return nil
+}
+
+// newMonitorChecks initializes the checks
+func newMonitorChecks(cfgChecks []*config.CheckInstance) ([]*monitor.Check, error) {
+	monitorChecks := []*monitor.Check{}
+	for _, cfgCheck := range cfgChecks {
+		check, err := newMonitorCheck(cfgCheck)
+		if err != nil {
+			return nil, fmt.Errorf("check %q: %w", cfgCheck.Name, err)
+		}
+		monitorChecks = append(monitorChecks, check)
+	}
+	return monitorChecks, nil
+}
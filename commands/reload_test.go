@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/config"
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/pkg/checks"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/spf13/viper"
+)
+
+func checkNames(cfgChecks []*config.CheckInstance) []string {
+	names := []string{}
+	for _, cfgCheck := range cfgChecks {
+		names = append(names, cfgCheck.Name)
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDiffChecks(t *testing.T) {
+	check := func(name, cron string) *config.CheckInstance {
+		return &config.CheckInstance{Name: name, Type: "http", Cron: cron, Config: map[string]interface{}{"url": "http://" + name}}
+	}
+
+	tests := []struct {
+		name              string
+		oldChecks         []*config.CheckInstance
+		newChecks         []*config.CheckInstance
+		expectedAdded     []string
+		expectedChanged   []string
+		expectedUnchanged []string
+		expectedRemoved   []string
+	}{
+		{
+			name:              "nothing changed",
+			oldChecks:         []*config.CheckInstance{check("api", "@every 1m"), check("web", "@every 1m")},
+			newChecks:         []*config.CheckInstance{check("api", "@every 1m"), check("web", "@every 1m")},
+			expectedUnchanged: []string{"api", "web"},
+		},
+		{
+			name:              "added, changed and removed",
+			oldChecks:         []*config.CheckInstance{check("api", "@every 1m"), check("web", "@every 1m"), check("db", "@every 1m")},
+			newChecks:         []*config.CheckInstance{check("cache", "@every 1m"), check("web", "@every 5m"), check("api", "@every 1m")},
+			expectedAdded:     []string{"cache"},
+			expectedChanged:   []string{"web"},
+			expectedUnchanged: []string{"api"},
+			expectedRemoved:   []string{"db"},
+		},
+		{
+			name:            "everything removed",
+			oldChecks:       []*config.CheckInstance{check("api", "@every 1m"), check("web", "@every 1m")},
+			newChecks:       []*config.CheckInstance{},
+			expectedRemoved: []string{"api", "web"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := diffChecks(test.oldChecks, test.newChecks)
+			for _, names := range []struct {
+				kind     string
+				got      []string
+				expected []string
+			}{
+				{"added", checkNames(diff.added), test.expectedAdded},
+				{"changed", checkNames(diff.changed), test.expectedChanged},
+				{"unchanged", checkNames(diff.unchanged), test.expectedUnchanged},
+				{"removed", diff.removed, test.expectedRemoved},
+			} {
+				if !equalNames(names.got, names.expected) {
+					t.Errorf("expected %s checks %v, got %v", names.kind, names.expected, names.got)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffChecksKeepsUnchangedChecks(t *testing.T) {
+	initialized := &checks.HTTPCheck{}
+	oldCheck := &config.CheckInstance{Name: "api", Type: "http", Cron: "@every 1m", Check: initialized}
+	newCheck := &config.CheckInstance{Name: "api", Type: "http", Cron: "@every 1m"}
+	diffChecks([]*config.CheckInstance{oldCheck}, []*config.CheckInstance{newCheck})
+	if newCheck.Check != initialized {
+		t.Errorf("expected an unchanged check to keep its initialized check")
+	}
+}
+
+func TestReuseNotifiers(t *testing.T) {
+	reused := &notifiers.WebhookNotifier{}
+	oldNotifiers := []*config.NotifierInstance{
+		{Name: "slack", Type: "webhook", Config: map[string]interface{}{"url": "http://slack"}, Notifier: reused},
+		{Name: "pager", Type: "webhook", Config: map[string]interface{}{"url": "http://pager"}, Notifier: &notifiers.WebhookNotifier{}},
+		{Name: "removed", Type: "webhook", Config: map[string]interface{}{"url": "http://removed"}, Notifier: &notifiers.WebhookNotifier{}},
+	}
+	newNotifiers := []*config.NotifierInstance{
+		{Name: "slack", Type: "webhook", Config: map[string]interface{}{"url": "http://slack"}},
+		{Name: "pager", Type: "webhook", Config: map[string]interface{}{"url": "http://pager/v2"}},
+		{Name: "email", Type: "webhook", Config: map[string]interface{}{"url": "http://email"}},
+	}
+
+	uninitialized := reuseNotifiers(oldNotifiers, newNotifiers)
+	names := []string{}
+	for _, cfgNotifier := range uninitialized {
+		names = append(names, cfgNotifier.Name)
+	}
+	if !equalNames(names, []string{"pager", "email"}) {
+		t.Errorf("expected the changed and new notifiers to be initialized, got %v", names)
+	}
+	if newNotifiers[0].Notifier != reused {
+		t.Errorf("expected the unchanged notifier to be reused")
+	}
+	if newNotifiers[1].Notifier != nil || newNotifiers[2].Notifier != nil {
+		t.Errorf("expected changed and new notifiers not to be reused")
+	}
+}
+
+func TestReloadConfigRejectsInvalidConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "muffin-reload")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "muffin.yaml")
+	// the first check is new and valid, the second one has an invalid cron expression
+	content := `
+checks:
+  - name: web
+    type: http
+    cron: "@every 1h"
+    config:
+      url: http://localhost/web
+  - name: api
+    type: http
+    cron: "every hour"
+    config:
+      url: http://localhost/api
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed writing config: %v", err)
+	}
+	viper.SetConfigFile(file)
+	defer viper.Reset()
+
+	mon := monitor.New(scheduler.New(), nil)
+	current := config.New()
+	current.Checks = []*config.CheckInstance{{Name: "api", Type: "http", Cron: "@every 1h", Config: map[string]interface{}{"url": "http://localhost/api"}}}
+	check, err := newMonitorCheck(current.Checks[0])
+	if err != nil {
+		t.Fatalf("failed initializing check: %v", err)
+	}
+	if err := mon.Add(check); err != nil {
+		t.Fatalf("failed adding check: %v", err)
+	}
+	cfg = current
+
+	if err := reloadConfig(mon); err == nil {
+		t.Fatalf("expected reloading an invalid config to fail")
+	}
+	if cfg != current {
+		t.Errorf("expected the current config to be kept")
+	}
+	statuses := mon.Checks()
+	if len(statuses) != 1 || statuses[0].Name != "api" {
+		t.Errorf("expected the monitor's checks not to change, got %v", statuses)
+	}
+}
+
+func TestWatchConfigStops(t *testing.T) {
+	dir, err := ioutil.TempDir("", "muffin-watch")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "muffin.yaml")
+	if err := ioutil.WriteFile(file, []byte("checks: []\n"), 0o600); err != nil {
+		t.Fatalf("failed writing config: %v", err)
+	}
+	viper.SetConfigFile(file)
+	defer viper.Reset()
+	current := config.New()
+	cfg = current
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		watchConfig(ctx, monitor.New(scheduler.New(), nil))
+	}()
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the watcher to stop once the context is cancelled")
+	}
+
+	if err := ioutil.WriteFile(file, []byte("checks: []\nlog:\n  level: info\n"), 0o600); err != nil {
+		t.Fatalf("failed writing config: %v", err)
+	}
+	time.Sleep(2 * reloadDebounce)
+	if cfg != current {
+		t.Errorf("expected the config not to be reloaded after the watcher stopped")
+	}
+}
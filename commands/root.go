@@ -16,10 +16,18 @@ var (
 	cfgFile string
 
 	cfg *config.Config
+	// cfgReadErr is the error of reading the config file, muffin runs with its
+	// defaults and environment variables without one
+	cfgReadErr error
+	// cfgErrs are the errors of decoding the config, they are reported by the
+	// commands rather than by initConfig so `muffin validate` can report them
+	// along with the rest of the config's errors
+	cfgErrs []*config.ValidationError
 
 	rootCmd = &cobra.Command{
-		Use:   "muffin",
-		Short: "Muffin is a simple use application to monitor network services",
+		Use:              "muffin",
+		Short:            "Muffin is a simple use application to monitor network services",
+		PersistentPreRun: initCommand,
 	}
 )
 
@@ -32,11 +40,13 @@ func Execute() {
 
 func init() {
 	cobra.OnInitialize(initConfig)
-	cobra.OnInitialize(initLog)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.muffin.yaml)")
 }
 
-func initLog() {
+func initCommand(cmd *cobra.Command, args []string) {
+	if len(cfgErrs) > 0 {
+		exitWithErrors(cfgErrs)
+	}
 	if err := logger.Init(cfg.Log.Level); err != nil {
 		exitWithError(err)
 	}
@@ -62,12 +72,14 @@ func initConfig() {
 	viper.SetEnvPrefix("muffin")
 	viper.AutomaticEnv() // read in environment variables that match
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	if cfgReadErr = viper.ReadInConfig(); cfgReadErr == nil {
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 
 	if err := viper.Unmarshal(cfg); err != nil {
-		exitWithError(err)
+		if cfgErrs = config.DecodeErrors(viper.AllSettings()); len(cfgErrs) == 0 {
+			exitWithError(err)
+		}
 	}
 }
 
@@ -75,3 +87,11 @@ func exitWithError(err error) {
 	fmt.Println(err)
 	os.Exit(1)
 }
+
+// exitWithErrors prints the errors of the config and exits
+func exitWithErrors(errs []*config.ValidationError) {
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amitizle/muffin/internal/config"
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run [check names...]",
+	Short: "run checks once",
+	Long: `Run the checks once, in parallel, and print their results. All of the checks
+are run unless check names or labels are given. The exit code is non-zero if
+any of the checks failed, so it can be used in CI pipelines.
+Notifications are not sent unless --notify is given, in which case the configured
+notifiers of every failed check are notified.`,
+	Run: runChecks,
+}
+
+// runResult is a result of a check that was run by `muffin run`
+type runResult struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Target    string  `json:"target,omitempty"`
+	Success   bool    `json:"success"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	TimedOut  bool    `json:"timed_out,omitempty"`
+	Output    string  `json:"output,omitempty"`
+}
+
+func init() {
+	runCmd.Flags().StringArrayP("label", "l", []string{}, "only run checks with the given label (key=value), can be given more than once")
+	runCmd.Flags().StringP("output", "o", "table", "output format, table or json")
+	runCmd.Flags().Bool("notify", false, "notify the configured notifiers of failed checks")
+	rootCmd.AddCommand(runCmd)
+}
+
+func runChecks(cmd *cobra.Command, args []string) {
+	labels, _ := cmd.Flags().GetStringArray("label")
+	output, _ := cmd.Flags().GetString("output")
+	notify, _ := cmd.Flags().GetBool("notify")
+	if output != "table" && output != "json" {
+		exitWithError(fmt.Errorf("unknown output format %q, use table or json", output))
+	}
+	// the results are written to stdout, so logs go to stderr
+	log.Logger = log.Logger.Output(os.Stderr)
+
+	selected, err := selectChecks(cfg.Checks, args, labels)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := cfg.ResolveNotifiers(); err != nil {
+		exitWithError(err)
+	}
+	if notify {
+		if err := initializeNotifiers(); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	mon := monitor.New(scheduler.New(), nil)
+	for _, cfgCheck := range selected {
+		check, err := newMonitorCheck(cfgCheck)
+		if err != nil {
+			exitWithError(fmt.Errorf("check %q: %w", cfgCheck.Name, err))
+		}
+		// a single run decides the state of the check, so a failure is notified
+		check.FailureThreshold, check.SuccessThreshold = 1, 1
+		if !notify {
+			check.Notifiers = nil
+		}
+		if err := mon.Add(check); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	results := make([]runResult, len(selected))
+	var wg sync.WaitGroup
+	for i, cfgCheck := range selected {
+		wg.Add(1)
+		go func(i int, cfgCheck *config.CheckInstance) {
+			defer wg.Done()
+			result, err := mon.Run(cfgCheck.Name)
+			if err != nil {
+				result = monitor.Result{Error: err.Error()}
+			}
+			results[i] = runResult{
+				Name:      cfgCheck.Name,
+				Type:      cfgCheck.Type,
+				Success:   result.Success,
+				LatencyMS: float64(result.Latency) / float64(time.Millisecond),
+				Error:     result.Error,
+				TimedOut:  result.TimedOut,
+				Output:    result.Output,
+			}
+			if status, err := mon.Check(cfgCheck.Name); err == nil {
+				results[i].Target = status.Target
+			}
+		}(i, cfgCheck)
+	}
+	wg.Wait()
+
+	if output == "json" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(string(b))
+	} else {
+		printResults(results)
+	}
+	for _, result := range results {
+		if !result.Success {
+			os.Exit(1)
+		}
+	}
+}
+
+// selectChecks returns the checks with the given names (or all checks if no names are
+// given) that have all of the given labels, it returns an error if a name does not exist
+// or if no check is selected
+func selectChecks(cfgChecks []*config.CheckInstance, names []string, labels []string) ([]*config.CheckInstance, error) {
+	wanted := map[string]string{}
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, labels are given as key=value", label)
+		}
+		wanted[parts[0]] = parts[1]
+	}
+
+	candidates := cfgChecks
+	if len(names) > 0 {
+		byName := map[string]*config.CheckInstance{}
+		for _, cfgCheck := range cfgChecks {
+			byName[cfgCheck.Name] = cfgCheck
+		}
+		candidates = []*config.CheckInstance{}
+		for _, name := range names {
+			cfgCheck, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("check %q does not exist", name)
+			}
+			candidates = append(candidates, cfgCheck)
+		}
+	}
+
+	selected := []*config.CheckInstance{}
+	for _, cfgCheck := range candidates {
+		matches := true
+		for key, value := range wanted {
+			if v, ok := cfgCheck.Labels[key]; !ok || v != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			selected = append(selected, cfgCheck)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no checks to run")
+	}
+	return selected, nil
+}
+
+// printResults prints the results as a table
+func printResults(results []runResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tTARGET\tRESULT\tLATENCY\tERROR")
+	for _, result := range results {
+		status := "ok"
+		if result.TimedOut {
+			status = "timeout"
+		} else if !result.Success {
+			status = "failed"
+		}
+		latency := time.Duration(result.LatencyMS * float64(time.Millisecond)).Round(time.Millisecond)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Name, result.Type, result.Target, status, latency, result.Error)
+	}
+	w.Flush()
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/amitizle/muffin/internal/config"
+)
+
+func TestSelectChecks(t *testing.T) {
+	cfgChecks := []*config.CheckInstance{
+		{Name: "api", Labels: map[string]string{"env": "prod", "team": "payments"}},
+		{Name: "web", Labels: map[string]string{"env": "prod", "team": "frontend"}},
+		{Name: "staging api", Labels: map[string]string{"env": "staging", "team": "payments"}},
+		{Name: "db"},
+	}
+
+	tests := []struct {
+		name       string
+		names      []string
+		labels     []string
+		expected   []string
+		shouldFail bool
+	}{
+		{name: "all checks", expected: []string{"api", "web", "staging api", "db"}},
+		{name: "by name", names: []string{"db", "api"}, expected: []string{"db", "api"}},
+		{name: "by label", labels: []string{"env=prod"}, expected: []string{"api", "web"}},
+		{name: "by labels", labels: []string{"env=prod", "team=payments"}, expected: []string{"api"}},
+		{name: "by name and label", names: []string{"api", "staging api"}, labels: []string{"env=staging"}, expected: []string{"staging api"}},
+		{name: "label without value", labels: []string{"env"}, shouldFail: true},
+		{name: "label without key", labels: []string{"=prod"}, shouldFail: true},
+		{name: "unknown name", names: []string{"api", "nope"}, shouldFail: true},
+		{name: "nothing matches", labels: []string{"env=dev"}, shouldFail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := selectChecks(cfgChecks, test.names, test.labels)
+			if test.shouldFail {
+				if err == nil {
+					t.Fatalf("expected selecting checks to fail, got %v", checkNames(selected))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error selecting checks: %v", err)
+			}
+			if names := checkNames(selected); !equalNames(names, test.expected) {
+				t.Errorf("expected checks %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/amitizle/muffin/internal/history"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/internal/server"
+	"github.com/rs/zerolog/log"
+)
+
+// waitForShutdown blocks until SIGINT or SIGTERM is received and then shuts down gracefully:
+// no new checks are started, the HTTP server stops accepting requests and running checks are
+// given until the shutdown timeout to finish. Notifications are sent as part of a check's run,
+// so waiting for the running checks also waits for their notifications to be sent.
+// `stopWatching` stops reloading the config (and waits for a running reload) before
+// anything is shut down. A second signal exits immediately.
+func waitForShutdown(s *scheduler.Scheduler, srv *server.Server, store *history.BoltStore, timeout time.Duration, stopWatching func()) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	log.Info().Str("signal", sig.String()).Dur("timeout", timeout).Int("running_tasks", s.Running()).Msg("shutting down")
+	go func() {
+		sig := <-signals
+		log.Warn().Str("signal", sig.String()).Msg("received a second signal, exiting immediately")
+		os.Exit(1)
+	}()
+	stopWatching()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Shutdown(ctx)
+	}()
+
+	if srv != nil {
+		// manual runs are requests to the server, so shutting it down waits for them
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("failed shutting down HTTP server gracefully")
+		}
+	}
+	if err := <-stopped; err != nil {
+		log.Error().Err(err).Int("running_tasks", s.Running()).Msg("running checks did not finish before the shutdown timeout")
+	}
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.Error().Err(err).Msg("failed closing history")
+		}
+	}
+	log.Info().Msg("shutdown complete")
+}
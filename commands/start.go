@@ -2,14 +2,23 @@ package commands
 
 import (
 	"context"
-	"fmt"
+	"time"
 
+	"github.com/amitizle/muffin/internal/api"
+	"github.com/amitizle/muffin/internal/config"
+	"github.com/amitizle/muffin/internal/dashboard"
+	"github.com/amitizle/muffin/internal/history"
 	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/metrics"
+	"github.com/amitizle/muffin/internal/monitor"
 	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/internal/server"
+	"github.com/amitizle/muffin/internal/statuspage"
 	"github.com/amitizle/muffin/pkg/checks"
 	"github.com/amitizle/muffin/pkg/notifiers"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // startCmd represents the start command
@@ -20,84 +29,206 @@ var startCmd = &cobra.Command{
 }
 
 func init() {
-	// startCmd.Flags().Bool("dry-run", false, "don't run the checks, just print that they were supposed to be running")
+	startCmd.Flags().String("listen", "", "address of the HTTP server exposing the dashboard, /metrics and the API (i.e :9090), disabled if empty")
+	viper.BindPFlag("server.listen", startCmd.Flags().Lookup("listen"))
+	startCmd.Flags().Duration("shutdown-timeout", config.DefaultShutdownTimeout, "how long to wait for running checks to finish when stopping")
+	viper.BindPFlag("shutdown_timeout", startCmd.Flags().Lookup("shutdown-timeout"))
 	rootCmd.AddCommand(startCmd)
 }
 
 func startScheduler(cmd *cobra.Command, args []string) {
+	if errs := cfg.Validate(); len(errs) > 0 {
+		exitWithErrors(errs)
+	}
+
+	if err := cfg.ResolveNotifiers(); err != nil {
+		exitWithError(err)
+	}
+
 	if err := initializeNotifiers(); err != nil {
 		exitWithError(err)
 	}
 
 	s := scheduler.New()
-	if err := initializeChecks(s); err != nil {
+	m := metrics.New()
+	m.RegisterScheduler(s)
+	mon := monitor.New(s, m)
+	var store *history.BoltStore
+	if cfg.History.Path != "" {
+		var err error
+		if store, err = initializeHistory(s, mon); err != nil {
+			exitWithError(err)
+		}
+	}
+	if err := initializeChecks(mon); err != nil {
 		exitWithError(err)
 	}
 
+	if cfg.StatusPage.Cron != "" {
+		if err := scheduleStatusPage(s, mon); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	var srv *server.Server
+	if cfg.Server.Listen != "" {
+		srv = server.New(cfg.Server.Listen, log.Logger)
+		srv.Handle("/metrics", m.Handler())
+		a := api.New(mon)
+		if !cfg.Server.ManualRuns {
+			a.DisableManualRuns()
+		}
+		a.Register(srv)
+		srv.Handle(dashboard.Path, dashboard.Handler())
+		if err := srv.Start(); err != nil {
+			exitWithError(err)
+		}
+	}
+
 	s.Start()
-	select {}
+	// cfg is replaced by reloads once the config is watched
+	shutdownTimeout := cfg.ShutdownTimeout
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		watchConfig(watchCtx, mon)
+	}()
+	waitForShutdown(s, srv, store, shutdownTimeout, func() {
+		stopWatching()
+		<-watching
+	})
 }
 
-func initializeChecks(s *scheduler.Scheduler) error {
+func initializeChecks(mon *monitor.Monitor) error {
 	for _, cfgCheck := range cfg.Checks {
-		checkLogger := log.With().Str("check_name", cfgCheck.Name).Str("check_type", cfgCheck.Type).Logger()
-		checkLogger.Info().Msg("initializing check")
-		check, err := checks.FromString(cfgCheck.Type)
+		check, err := newMonitorCheck(cfgCheck)
 		if err != nil {
 			return err
 		}
-
-		ctx := context.Background()
-		ctxWithLog := logger.StoreContext(ctx, checkLogger)
-
-		if err := check.Initialize(ctxWithLog); err != nil {
+		if err := mon.Add(check); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		if err := check.Configure(cfgCheck.Config); err != nil {
-			return err
+// newMonitorCheck initializes and configures the check and returns it
+// along with its notifiers as a monitor check
+func newMonitorCheck(cfgCheck *config.CheckInstance) (*monitor.Check, error) {
+	checkLogger := log.With().Str("check_name", cfgCheck.Name).Str("check_type", cfgCheck.Type).Logger()
+	checkLogger.Info().Msg("initializing check")
+	check, err := checks.FromString(cfgCheck.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	ctxWithLog := logger.StoreContext(ctx, checkLogger)
+
+	if err := check.Initialize(ctxWithLog); err != nil {
+		return nil, err
+	}
+
+	if err := check.Configure(cfgCheck.Config); err != nil {
+		return nil, err
+	}
+	cfgCheck.Check = check
+
+	return &monitor.Check{
+		Name:             cfgCheck.Name,
+		Type:             cfgCheck.Type,
+		Cron:             cfgCheck.Cron,
+		Labels:           cfgCheck.Labels,
+		Severity:         cfgCheck.Severity,
+		Timeout:          cfgCheck.Timeout,
+		FailureThreshold: cfgCheck.FailureThreshold,
+		SuccessThreshold: cfgCheck.SuccessThreshold,
+		Check:            check,
+		Notifiers:        routedNotifiers(cfgCheck),
+		Logger:           checkLogger,
+	}, nil
+}
+
+// routedNotifiers returns the initialized notifiers the check is routed to
+func routedNotifiers(cfgCheck *config.CheckInstance) []*monitor.Notifier {
+	routed := []*monitor.Notifier{}
+	for _, notifier := range cfgCheck.RoutedNotifiers {
+		routed = append(routed, &monitor.Notifier{Name: notifier.Name, Notifier: notifier.Notifier})
+	}
+	return routed
+}
+
+// initializeHistory opens the history store, sets it as the monitor's store and
+// schedules pruning it hourly
+func initializeHistory(s *scheduler.Scheduler, mon *monitor.Monitor) (*history.BoltStore, error) {
+	historyLogger := log.With().Str("path", cfg.History.Path).Logger()
+	store, err := history.Open(cfg.History)
+	if err != nil {
+		return nil, err
+	}
+	historyLogger.Info().Msg("opened history")
+	mon.SetStore(store)
+	err = s.NewTask("0 0 * * * *", func() {
+		configured := []string{}
+		for _, status := range mon.Checks() {
+			configured = append(configured, status.Name)
 		}
-		cfgCheck.Check = check
-		err = s.NewTask(cfgCheck.Cron, func() {
-			b, err := check.Run()
-			if err != nil {
-				log.Error().Err(err).Msg("failed check")
-				for _, notifier := range cfg.Notifiers {
-					if err := notifier.Notifier.Notify(fmt.Sprintf("check %s error: %s", cfgCheck.Name, err)); err != nil {
-						log.Error().Err(err).Msg("failed notifying")
-					}
-				}
-			}
-			log.Info().Str("result", string(b)).Msg("check finished")
-		})
-		if err != nil {
-			exitWithError(err)
+		if err := store.Prune(time.Now(), configured); err != nil {
+			historyLogger.Error().Err(err).Msg("failed pruning history")
 		}
+	})
+	if err != nil {
+		store.Close()
+		return nil, err
 	}
-	return nil
+	return store, nil
+}
+
+// scheduleStatusPage renders the status page periodically from the monitor's checks
+func scheduleStatusPage(s *scheduler.Scheduler, mon *monitor.Monitor) error {
+	r, err := statuspage.New(cfg.StatusPage)
+	if err != nil {
+		return err
+	}
+	statusPageLogger := log.With().Str("output", cfg.StatusPage.Output).Logger()
+	return s.NewTask(cfg.StatusPage.Cron, func() {
+		if err := r.Render(statuspage.MonitorSource(mon)); err != nil {
+			statusPageLogger.Error().Err(err).Msg("failed rendering status page")
+			return
+		}
+		statusPageLogger.Debug().Msg("rendered status page")
+	})
 }
 
 func initializeNotifiers() error {
 	for _, cfgNotifier := range cfg.Notifiers {
-		notifierLogger := log.With().Str("notifier", cfgNotifier.Name).Str("notifier_type", cfgNotifier.Type).Logger()
-		notifierLogger.Info().Msg("initializing check")
-		notifier, err := notifiers.FromString(cfgNotifier.Type)
-		if err != nil {
+		if err := initializeNotifier(cfgNotifier); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		ctx := context.Background()
-		ctxWithLog := logger.StoreContext(ctx, notifierLogger)
+func initializeNotifier(cfgNotifier *config.NotifierInstance) error {
+	notifierLogger := log.With().Str("notifier", cfgNotifier.Name).Str("notifier_type", cfgNotifier.Type).Logger()
+	notifierLogger.Info().Msg("initializing notifier")
+	notifier, err := notifiers.FromString(cfgNotifier.Type)
+	if err != nil {
+		return err
+	}
 
-		if err := notifier.Initialize(ctxWithLog); err != nil {
-			return err
-		}
+	ctx := context.Background()
+	ctxWithLog := logger.StoreContext(ctx, notifierLogger)
 
-		if err := notifier.Configure(cfgNotifier.Config); err != nil {
-			return err
-		}
+	if err := notifier.Initialize(ctxWithLog); err != nil {
+		return err
+	}
 
-		cfgNotifier.Notifier = notifier
+	if err := notifier.Configure(cfgNotifier.Config); err != nil {
+		return err
 	}
+
+	cfgNotifier.Notifier = notifier
 	return nil
 }
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/amitizle/muffin/internal/api"
+	"github.com/amitizle/muffin/internal/statuspage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statusPageCmd represents the status-page command
+var statusPageCmd = &cobra.Command{
+	Use:   "status-page",
+	Short: "render the status page from a running muffin",
+	Long: `Render the public status page (HTML and JSON) from the checks and incidents of a
+running muffin, using its API. The daemon has to be started with server.listen set,
+its address is derived from server.listen of this config file unless --from is given.
+The output directory can then be published, i.e synced to object storage, without
+exposing muffin itself.
+The status page can also be rendered periodically by "muffin start", see status_page.cron.`,
+	Run: renderStatusPage,
+}
+
+func init() {
+	statusPageCmd.Flags().String("from", "", "URL of the muffin API to render the status page from (default is derived from server.listen)")
+	statusPageCmd.Flags().String("output", "", "directory the status page is written to (default is status_page.output)")
+	statusPageCmd.Flags().String("template", "", "path of a custom status page template (default is status_page.template)")
+	viper.BindPFlag("status_page.output", statusPageCmd.Flags().Lookup("output"))
+	viper.BindPFlag("status_page.template", statusPageCmd.Flags().Lookup("template"))
+	rootCmd.AddCommand(statusPageCmd)
+}
+
+func renderStatusPage(cmd *cobra.Command, args []string) {
+	from, _ := cmd.Flags().GetString("from")
+	if from == "" {
+		from = apiURL(cfg.Server.Listen)
+	}
+	if from == "" {
+		exitWithError(errors.New("the muffin API URL is unknown, use --from or configure server.listen"))
+	}
+
+	r, err := statuspage.New(cfg.StatusPage)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := r.Render(api.NewClient(from)); err != nil {
+		exitWithError(err)
+	}
+	fmt.Println("status page written to", cfg.StatusPage.Output)
+}
+
+// apiURL returns the URL of the API served on the given listen address,
+// i.e http://localhost:9090 for ":9090"
+func apiURL(listen string) string {
+	if listen == "" {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return ""
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
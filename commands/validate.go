@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/amitizle/muffin/internal/config"
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/offline"
+	"github.com/amitizle/muffin/internal/statuspage"
+	"github.com/amitizle/muffin/pkg/checks"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate the config file",
+	Long: `Validate the config file without starting muffin. Every check and notifier is
+configured offline, so tokens and channels are not verified against their services,
+but secrets given by environment variables or files have to be resolvable.
+All of the errors are printed with their path in the config file, and the exit
+code is non-zero if there are any, so it can be used in CI.`,
+	// the log level is validated with the rest of the config instead of
+	// initializing the logger with it
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run:              validateConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func validateConfig(cmd *cobra.Command, args []string) {
+	if cfgReadErr != nil {
+		exitWithError(fmt.Errorf("failed reading config: %w", cfgReadErr))
+	}
+
+	// a config that can't be decoded is only partly decoded, so the rest
+	// of it is validated once the decoding errors are fixed
+	errs := cfgErrs
+	if len(errs) == 0 {
+		errs = validationErrors()
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		fmt.Printf("%s is invalid, found %d error(s)\n", viper.ConfigFileUsed(), len(errs))
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid (%d checks, %d notifiers)\n", viper.ConfigFileUsed(), len(cfg.Checks), len(cfg.Notifiers))
+}
+
+// validationErrors returns all of the errors of the config, including the errors
+// of configuring its checks and notifiers
+func validationErrors() []*config.ValidationError {
+	errs := cfg.Validate()
+	for i, cfgNotifier := range cfg.Notifiers {
+		notifier, err := notifiers.FromString(cfgNotifier.Type)
+		if err != nil {
+			// unknown types were already reported
+			continue
+		}
+		if err := configureOffline(notifier, cfgNotifier.Config); err != nil {
+			errs = append(errs, configErrors(fmt.Sprintf("notifiers[%d].config", i), err)...)
+		}
+	}
+	for i, cfgCheck := range cfg.Checks {
+		check, err := checks.FromString(cfgCheck.Type)
+		if err != nil {
+			continue
+		}
+		if err := configureOffline(check, cfgCheck.Config); err != nil {
+			errs = append(errs, configErrors(fmt.Sprintf("checks[%d].config", i), err)...)
+		}
+	}
+	if cfg.StatusPage.Template != "" {
+		if _, err := statuspage.New(cfg.StatusPage); err != nil {
+			errs = append(errs, &config.ValidationError{Path: "status_page.template", Err: err})
+		}
+	}
+	return errs
+}
+
+// configErrors returns the validation errors of a check's or a notifier's config,
+// with the path of the failing field when it's known
+func configErrors(path string, err error) []*config.ValidationError {
+	var decodeErrs mapdecode.Errors
+	if errors.As(err, &decodeErrs) {
+		errs := []*config.ValidationError{}
+		for _, fieldErr := range decodeErrs {
+			errs = append(errs, &config.ValidationError{Path: fieldPath(path, fieldErr.Field), Err: fieldErr.Err})
+		}
+		return errs
+	}
+	var fieldErr *mapdecode.FieldError
+	if errors.As(err, &fieldErr) {
+		return []*config.ValidationError{{Path: fieldPath(path, fieldErr.Field), Err: fieldErr.Err}}
+	}
+	return []*config.ValidationError{{Path: path, Err: err}}
+}
+
+// fieldPath joins the path of a config with the path of one of its fields
+func fieldPath(path, field string) string {
+	if field == "" {
+		return path
+	}
+	return path + "." + field
+}
+
+// configurable is what checks and notifiers have in common
+type configurable interface {
+	Initialize(context.Context) error
+	Configure(map[string]interface{}) error
+}
+
+// configureOffline initializes and configures a check or a notifier
+// with an offline context and without logging
+func configureOffline(c configurable, cfg map[string]interface{}) error {
+	ctx := offline.StoreContext(logger.StoreContext(context.Background(), zerolog.Nop()))
+	if err := c.Initialize(ctx); err != nil {
+		return err
+	}
+	return c.Configure(cfg)
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/amitizle/muffin/pkg/checks"
+)
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		checkType     string
+		config        map[string]interface{}
+		expectedPaths []string
+	}{
+		{name: "decode errors", checkType: "tcp", config: map[string]interface{}{"host": []string{"db"}, "port": "x"}, expectedPaths: []string{"checks[0].config.host", "checks[0].config.port"}},
+		{name: "invalid field", checkType: "tcp", config: map[string]interface{}{"host": "db", "port": 70000}, expectedPaths: []string{"checks[0].config.port"}},
+		{name: "nested field", checkType: "http", config: map[string]interface{}{"url": "http://example.com", "json_assertions": []map[string]interface{}{{"path": "status", "operator": "is"}}}, expectedPaths: []string{"checks[0].config.json_assertions[0]"}},
+		{name: "unknown key", checkType: "tcp", config: map[string]interface{}{"host": "db", "port": 5432, "hots": "db"}, expectedPaths: []string{"checks[0].config.hots"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check, err := checks.FromString(test.checkType)
+			if err != nil {
+				t.Fatalf("unknown check type: %v", err)
+			}
+			err = configureOffline(check, test.config)
+			if err == nil {
+				t.Fatalf("expected configuring the check to fail")
+			}
+			errs := configErrors("checks[0].config", err)
+			paths := map[string]bool{}
+			for _, err := range errs {
+				paths[err.Path] = true
+			}
+			if len(paths) != len(test.expectedPaths) {
+				t.Fatalf("expected paths %v, got %v", test.expectedPaths, errs)
+			}
+			for _, path := range test.expectedPaths {
+				if !paths[path] {
+					t.Fatalf("expected an error with path %s, got %v", path, errs)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown field", func(t *testing.T) {
+		errs := configErrors("checks[0].config", fmt.Errorf("wrapped: %w", errors.New("failed")))
+		if len(errs) != 1 || errs[0].Path != "checks[0].config" {
+			t.Fatalf("expected the error to have the config's path, got %v", errs)
+		}
+	})
+}
@@ -1,13 +1,16 @@
 module github.com/amitizle/muffin
 
-go 1.13
+go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/gorilla/websocket v1.4.1 // indirect
+	github.com/miekg/dns v1.1.29
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/nlopes/slack v0.6.0
 	github.com/pelletier/go-toml v1.6.0 // indirect
+	github.com/prometheus/client_golang v1.12.2
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/rs/zerolog v1.17.2
 	github.com/spf13/afero v1.2.2 // indirect
@@ -16,8 +19,7 @@ require (
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/spf13/viper v1.6.1
-	golang.org/x/sys v0.0.0-20200107162124-548cf772de50 // indirect
-	golang.org/x/text v0.3.2 // indirect
+	github.com/tidwall/gjson v1.6.8
+	go.etcd.io/bbolt v1.3.6
 	gopkg.in/ini.v1 v1.51.1 // indirect
-	gopkg.in/yaml.v2 v2.2.7 // indirect
 )
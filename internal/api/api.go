@@ -0,0 +1,295 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+const (
+	// ChecksPath is the path prefix of the checks API
+	ChecksPath = "/api/v1/checks"
+	// IncidentsPath is the path of the incidents API
+	IncidentsPath = "/api/v1/incidents"
+)
+
+// API is the JSON REST API of the daemon:
+//
+//	GET  /api/v1/checks                list all checks and their status
+//	GET  /api/v1/checks/{name}         get the status of a single check
+//	GET  /api/v1/checks/{name}/history get the recent results of a check (?limit=N)
+//	POST /api/v1/checks/{name}/run     run a check immediately and get its result, unless disabled
+//	GET  /api/v1/incidents             list incidents of all checks, newest first (?since=RFC3339)
+//	GET  /healthz                      the health of the daemon itself
+//
+// The API is not authenticated. Manual runs notify like scheduled runs do, so they
+// can be disabled (see `DisableManualRuns`) when the API is reachable by untrusted clients.
+type API struct {
+	monitor    *monitor.Monitor
+	started    time.Time
+	manualRuns bool
+}
+
+// New returns a new API that serves the given monitor's checks
+func New(m *monitor.Monitor) *API {
+	return &API{
+		monitor:    m,
+		started:    time.Now(),
+		manualRuns: true,
+	}
+}
+
+// DisableManualRuns makes the API reject requests to run checks
+func (a *API) DisableManualRuns() {
+	a.manualRuns = false
+}
+
+// Register registers the API's handlers on the given mux
+// (i.e `*http.ServeMux` or `*server.Server`)
+func (a *API) Register(mux interface {
+	Handle(string, http.Handler)
+}) {
+	mux.Handle(ChecksPath, http.HandlerFunc(a.listChecks))
+	mux.Handle(ChecksPath+"/", http.HandlerFunc(a.check))
+	mux.Handle(IncidentsPath, http.HandlerFunc(a.listIncidents))
+	mux.Handle("/healthz", http.HandlerFunc(a.healthz))
+}
+
+type resultResponse struct {
+	Time      time.Time `json:"time"`
+	Success   bool      `json:"success"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	TimedOut  bool      `json:"timed_out,omitempty"`
+	Output    string    `json:"output,omitempty"`
+}
+
+type checkResponse struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Target     string            `json:"target,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Severity   string            `json:"severity"`
+	State      state.State       `json:"state"`
+	Since      *time.Time        `json:"since,omitempty"`
+	LastResult *resultResponse   `json:"last_result,omitempty"`
+	// LastError is the error of the most recent failed run, which is
+	// not necessarily the last run, LastErrorAt is the time of that run
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	// Uptime is the percentage of successful runs by window (i.e "24h", "7d"),
+	// it's null for windows without any runs
+	Uptime map[string]*float64 `json:"uptime"`
+	// UptimeRuns is the number of runs by window that the uptime is calculated from
+	UptimeRuns map[string]int `json:"uptime_runs"`
+}
+
+type incidentResponse struct {
+	Check    string     `json:"check"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Ongoing  bool       `json:"ongoing"`
+	Duration float64    `json:"duration_seconds"`
+	Error    string     `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string  `json:"status"`
+	Checks int     `json:"checks"`
+	Uptime float64 `json:"uptime_seconds"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (a *API) listChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	statuses := a.monitor.Checks()
+	response := make([]checkResponse, 0, len(statuses))
+	for _, status := range statuses {
+		response = append(response, newCheckResponse(status))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// check routes the requests of a single check by the path's suffix
+func (a *API) check(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, ChecksPath+"/")
+	action := ""
+	for _, suffix := range []string{"history", "run"} {
+		if strings.HasSuffix(name, "/"+suffix) {
+			name, action = strings.TrimSuffix(name, "/"+suffix), suffix
+			break
+		}
+	}
+
+	method := http.MethodGet
+	if action == "run" {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	switch action {
+	case "history":
+		a.checkHistory(w, r, name)
+	case "run":
+		a.runCheck(w, name)
+	default:
+		status, err := a.monitor.Check(name)
+		if err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newCheckResponse(status))
+	}
+}
+
+func (a *API) checkHistory(w http.ResponseWriter, r *http.Request, name string) {
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a non-negative number"))
+			return
+		}
+		limit = n
+	}
+	history, err := a.monitor.History(name, limit)
+	if err != nil {
+		writeMonitorError(w, err)
+		return
+	}
+	response := make([]*resultResponse, 0, len(history))
+	for _, result := range history {
+		response = append(response, newResultResponse(result))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (a *API) runCheck(w http.ResponseWriter, name string) {
+	if !a.manualRuns {
+		writeError(w, http.StatusForbidden, errors.New("manual runs are disabled"))
+		return
+	}
+	result, err := a.monitor.Run(name)
+	if err != nil {
+		writeMonitorError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newResultResponse(result))
+}
+
+func (a *API) listIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	since := time.Time{}
+	if s := r.URL.Query().Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("since must be an RFC3339 timestamp"))
+			return
+		}
+		since = t
+	}
+	now := time.Now()
+	incidents := a.monitor.Incidents(since)
+	response := make([]incidentResponse, 0, len(incidents))
+	for _, incident := range incidents {
+		response = append(response, incidentResponse{
+			Check:    incident.Check,
+			Start:    incident.Start,
+			End:      optionalTime(incident.End),
+			Ongoing:  incident.Ongoing(),
+			Duration: incident.Duration(now).Seconds(),
+			Error:    incident.Error,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (a *API) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{
+		Status: "ok",
+		Checks: len(a.monitor.Checks()),
+		Uptime: time.Since(a.started).Seconds(),
+	})
+}
+
+func newCheckResponse(status monitor.Status) checkResponse {
+	response := checkResponse{
+		Name:     status.Name,
+		Type:     status.Type,
+		Target:   status.Target,
+		Labels:   status.Labels,
+		Severity: status.Severity,
+		State:    status.State,
+		Since:    optionalTime(status.Since),
+		NextRun:  optionalTime(status.NextRun),
+		Uptime:   monitor.UptimePercentages(status.Uptime),
+	}
+	response.UptimeRuns = map[string]int{}
+	for _, uptime := range status.Uptime {
+		response.UptimeRuns[uptime.WindowName()] = uptime.Runs
+	}
+	if status.LastResult != nil {
+		response.LastResult = newResultResponse(*status.LastResult)
+	}
+	if status.LastFailure != nil {
+		response.LastError = status.LastFailure.Error
+		response.LastErrorAt = optionalTime(status.LastFailure.Time)
+	}
+	return response
+}
+
+func newResultResponse(result monitor.Result) *resultResponse {
+	return &resultResponse{
+		Time:      result.Time,
+		Success:   result.Success,
+		LatencyMS: float64(result.Latency) / float64(time.Millisecond),
+		Error:     result.Error,
+		TimedOut:  result.TimedOut,
+		Output:    result.Output,
+	}
+}
+
+// optionalTime returns nil for zero times so they're omitted from responses
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeMonitorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, monitor.ErrCheckNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
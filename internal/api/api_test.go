@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/rs/zerolog"
+)
+
+type fakeCheck struct {
+	err error
+}
+
+func (c *fakeCheck) Initialize(context.Context) error       { return nil }
+func (c *fakeCheck) Configure(map[string]interface{}) error { return nil }
+func (c *fakeCheck) Run(context.Context) ([]byte, error)    { return []byte{}, c.err }
+
+func newTestMonitor(t *testing.T) *monitor.Monitor {
+	m := monitor.New(scheduler.New(), nil)
+	for _, check := range []struct {
+		name string
+		err  error
+	}{{"payments api", errors.New("connection refused")}, {"web", nil}} {
+		err := m.Add(&monitor.Check{
+			Name:   check.name,
+			Type:   "fake",
+			Cron:   "0 0 * * * *",
+			Check:  &fakeCheck{err: check.err},
+			Logger: zerolog.Nop(),
+		})
+		if err != nil {
+			t.Fatalf("failed adding check: %v", err)
+		}
+	}
+	return m
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	New(newTestMonitor(t)).Register(mux)
+	return httptest.NewServer(mux)
+}
+
+func TestAPI(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expected       string
+	}{
+		{name: "health", method: "GET", path: "/healthz", expectedStatus: 200, expected: `{"status":"ok","checks":2}`},
+		{name: "list checks", method: "GET", path: "/api/v1/checks", expectedStatus: 200, expected: `[{"name":"payments api"},{"name":"web"}]`},
+		{name: "get check", method: "GET", path: "/api/v1/checks/web", expectedStatus: 200, expected: `{"name":"web","state":"unknown","severity":"","uptime_runs":{"24h":0}}`},
+		{name: "unknown check", method: "GET", path: "/api/v1/checks/nope", expectedStatus: 404},
+		{name: "run check", method: "POST", path: "/api/v1/checks/payments%20api/run", expectedStatus: 200, expected: `{"success":false,"error":"connection refused"}`},
+		{name: "run check with GET", method: "GET", path: "/api/v1/checks/web/run", expectedStatus: 405},
+		{name: "get failing check", method: "GET", path: "/api/v1/checks/payments%20api", expectedStatus: 200, expected: `{"state":"failing","last_error":"connection refused","uptime_runs":{"24h":1}}`},
+		{name: "history", method: "GET", path: "/api/v1/checks/payments%20api/history?limit=5", expectedStatus: 200, expected: `[{"success":false}]`},
+		{name: "incidents", method: "GET", path: "/api/v1/incidents", expectedStatus: 200, expected: `[{"check":"payments api","ongoing":true,"error":"connection refused"}]`},
+		{name: "no incidents since a later time", method: "GET", path: "/api/v1/incidents?since=2100-01-01T00:00:00Z", expectedStatus: 200, expected: `[]`},
+		{name: "incidents with invalid since", method: "GET", path: "/api/v1/incidents?since=yesterday", expectedStatus: 400},
+		{name: "history with invalid limit", method: "GET", path: "/api/v1/checks/web/history?limit=x", expectedStatus: 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, srv.URL+test.path, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d", test.expectedStatus, resp.StatusCode)
+			}
+			var body interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+			if test.expected == "" {
+				return
+			}
+			var expected interface{}
+			json.Unmarshal([]byte(test.expected), &expected)
+			if !contains(body, expected) {
+				t.Fatalf("expected response %v to contain %v", body, expected)
+			}
+		})
+	}
+}
+
+func TestDisableManualRuns(t *testing.T) {
+	m := newTestMonitor(t)
+	a := New(m)
+	a.DisableManualRuns()
+	mux := http.NewServeMux()
+	a.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/v1/checks/web/run", "", nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if status, _ := m.Check("web"); status.LastResult != nil {
+		t.Fatalf("expected the check not to run, got result %+v", status.LastResult)
+	}
+}
+
+// contains returns true if every field of `expected` is found in `actual`,
+// arrays are compared element by element
+func contains(actual, expected interface{}) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range e {
+			if !contains(a[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !contains(a[i], e[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return actual == expected
+}
+
+func TestClient(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	client := NewClient(srv.URL + "/")
+	if _, err := http.Post(srv.URL+"/api/v1/checks/payments%20api/run", "", nil); err != nil {
+		t.Fatalf("failed running check: %v", err)
+	}
+
+	statuses, err := client.Checks()
+	if err != nil {
+		t.Fatalf("failed getting checks: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(statuses))
+	}
+	failing := statuses[0]
+	if failing.Name != "payments api" || failing.State.String() != "failing" || failing.LastResult == nil || failing.LastResult.Error != "connection refused" {
+		t.Errorf("unexpected status of a failing check: %+v", failing)
+	}
+	if len(failing.Uptime) != 3 || failing.Uptime[0].Runs != 1 || failing.Uptime[0].Ratio != 0 {
+		t.Errorf("expected 0%% uptime over a single run, got %+v", failing.Uptime)
+	}
+	if statuses[1].Uptime[0].Runs != 0 {
+		t.Errorf("expected no runs for a check that did not run, got %+v", statuses[1].Uptime)
+	}
+
+	incidents, err := client.Incidents(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("failed getting incidents: %v", err)
+	}
+	if len(incidents) != 1 || !incidents[0].Ongoing() || incidents[0].Check != "payments api" {
+		t.Errorf("expected a single ongoing incident, got %+v", incidents)
+	}
+
+	if _, err := NewClient("http://127.0.0.1:1").Checks(); err == nil {
+		t.Errorf("expected an error from an unreachable API")
+	}
+}
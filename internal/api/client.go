@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+)
+
+// DefaultClientTimeout is the timeout of the client's requests
+const DefaultClientTimeout = 10 * time.Second
+
+// Client is a client of a running daemon's API, it returns the same
+// types as the `monitor` package so it can be used in place of a monitor
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a new client of the API served on `baseURL` (i.e http://localhost:9090)
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultClientTimeout},
+	}
+}
+
+// Checks returns the statuses of all of the daemon's checks
+func (c *Client) Checks() ([]monitor.Status, error) {
+	response := []checkResponse{}
+	if err := c.get(ChecksPath, &response); err != nil {
+		return nil, err
+	}
+	statuses := make([]monitor.Status, 0, len(response))
+	for _, check := range response {
+		status := monitor.Status{
+			Name:     check.Name,
+			Type:     check.Type,
+			Target:   check.Target,
+			Labels:   check.Labels,
+			Severity: check.Severity,
+			State:    check.State,
+		}
+		if check.Since != nil {
+			status.Since = *check.Since
+		}
+		if check.NextRun != nil {
+			status.NextRun = *check.NextRun
+		}
+		if check.LastResult != nil {
+			result := newResult(check.LastResult)
+			status.LastResult = &result
+		}
+		if check.LastErrorAt != nil {
+			status.LastFailure = &monitor.Result{Time: *check.LastErrorAt, Error: check.LastError}
+		}
+		for _, window := range monitor.UptimeWindows {
+			uptime := monitor.Uptime{Window: window}
+			uptime.Runs = check.UptimeRuns[uptime.WindowName()]
+			if percentage := check.Uptime[uptime.WindowName()]; percentage != nil {
+				uptime.Ratio = *percentage / 100
+			}
+			status.Uptime = append(status.Uptime, uptime)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Incidents returns the incidents of all of the daemon's checks that started after `since`
+func (c *Client) Incidents(since time.Time) ([]monitor.Incident, error) {
+	path := IncidentsPath
+	if !since.IsZero() {
+		path += "?since=" + url.QueryEscape(since.Format(time.RFC3339))
+	}
+	response := []incidentResponse{}
+	if err := c.get(path, &response); err != nil {
+		return nil, err
+	}
+	incidents := make([]monitor.Incident, 0, len(response))
+	for _, incident := range response {
+		i := monitor.Incident{Check: incident.Check, Start: incident.Start, Error: incident.Error}
+		if incident.End != nil {
+			i.End = *incident.End
+		}
+		incidents = append(incidents, i)
+	}
+	return incidents, nil
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return fmt.Errorf("failed requesting the muffin API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		errResponse := errorResponse{}
+		json.NewDecoder(resp.Body).Decode(&errResponse)
+		return fmt.Errorf("muffin API returned %s for %s: %s", resp.Status, path, errResponse.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed decoding muffin API response for %s: %w", path, err)
+	}
+	return nil
+}
+
+func newResult(response *resultResponse) monitor.Result {
+	return monitor.Result{
+		Time:     response.Time,
+		Success:  response.Success,
+		Latency:  time.Duration(response.LatencyMS * float64(time.Millisecond)),
+		Error:    response.Error,
+		TimedOut: response.TimedOut,
+		Output:   response.Output,
+	}
+}
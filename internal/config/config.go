@@ -1,6 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/amitizle/muffin/internal/history"
+	"github.com/amitizle/muffin/internal/statuspage"
 	"github.com/amitizle/muffin/pkg/checks"
 	"github.com/amitizle/muffin/pkg/notifiers"
 	"github.com/spf13/viper"
@@ -9,26 +15,92 @@ import (
 // Config is the struct that holds the entire configuration
 // for the app
 type Config struct {
-	Checks    []*checkInstance   `yaml:"checks"`
-	Notifiers []*notifierInstace `yaml:"notifiers"`
-	Log       *LogConfig         `yaml:"log"`
+	Checks    []*CheckInstance    `yaml:"checks"`
+	Notifiers []*NotifierInstance `yaml:"notifiers"`
+	Log       *LogConfig          `yaml:"log"`
+	Server    *ServerConfig       `yaml:"server"`
+	// StatusPage is the configuration of the public status page,
+	// see `muffin status-page`
+	StatusPage *statuspage.Config `yaml:"status_page" mapstructure:"status_page"`
+	// History is the configuration of the history store, that persists the
+	// results and state of checks across restarts
+	History *history.Config `yaml:"history"`
+	// ShutdownTimeout is how long `muffin start` waits for running checks
+	// (and their notifications) to finish when it's stopped
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" mapstructure:"shutdown_timeout"`
 }
 
-type checkInstance struct {
+// CheckInstance is the configuration of a single check, `Check` is
+// the check itself once it's initialized
+type CheckInstance struct {
 	Check checks.Check
 
-	Type   string `yaml:"type"`
-	Cron   string `yaml:"cron"`
-	Name   string `yaml:"name"`
-	Config map[string]interface{}
+	Type    string            `yaml:"type"`
+	Cron    string            `yaml:"cron"`
+	Name    string            `yaml:"name"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Labels  map[string]string `yaml:"labels"`
+	Config  map[string]interface{}
+
+	// FailureThreshold is the number of consecutive failed runs before the check
+	// is considered down, SuccessThreshold is the number of consecutive successful
+	// runs before it's considered up again. Both default to 1.
+	FailureThreshold int `yaml:"failure_threshold" mapstructure:"failure_threshold"`
+	SuccessThreshold int `yaml:"success_threshold" mapstructure:"success_threshold"`
+
+	// Notifiers are the names of the notifiers this check notifies, all notifiers
+	// are notified if it's empty. Severity is matched against the notifiers' severities.
+	Notifiers []string `yaml:"notifiers"`
+	Severity  string   `yaml:"severity"`
+
+	// RoutedNotifiers are the notifiers this check notifies, it is populated
+	// by `ResolveNotifiers`
+	RoutedNotifiers []*NotifierInstance `mapstructure:"-"`
 }
 
-type notifierInstace struct {
+// NotifierInstance is the configuration of a single notifier, `Notifier` is
+// the notifier itself once it's initialized
+type NotifierInstance struct {
 	Notifier notifiers.Notifier
 
 	Type   string                 `yaml:"type"`
 	Name   string                 `yaml:"name"`
 	Config map[string]interface{} `yaml:"config"`
+
+	// Severities limits the notifier to checks with one of the given
+	// severities, it receives notifications of all checks if it's empty
+	Severities []string `yaml:"severities"`
+}
+
+// Equal returns true if both checks have the same configuration,
+// the initialized check and the routed notifiers are not compared
+func (c *CheckInstance) Equal(other *CheckInstance) bool {
+	a, b := *c, *other
+	a.Check, b.Check = nil, nil
+	a.RoutedNotifiers, b.RoutedNotifiers = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// Equal returns true if both notifiers have the same configuration,
+// the initialized notifier is not compared
+func (n *NotifierInstance) Equal(other *NotifierInstance) bool {
+	a, b := *n, *other
+	a.Notifier, b.Notifier = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// acceptsSeverity returns true if the notifier should be notified
+// about checks with the given severity
+func (n *NotifierInstance) acceptsSeverity(severity string) bool {
+	if len(n.Severities) == 0 {
+		return true
+	}
+	for _, s := range n.Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
 }
 
 // LogConfig is the struct that holds the configuration for the logger
@@ -36,14 +108,79 @@ type LogConfig struct {
 	Level string `yaml:"level"`
 }
 
+// DefaultShutdownTimeout is the default `ShutdownTimeout`, it's lower than the
+// default termination grace period of kubernetes pods (30 seconds)
+const DefaultShutdownTimeout = 25 * time.Second
+
+// DefaultSeverity is the severity of checks that don't have one configured
+const DefaultSeverity = "critical"
+
+// ServerConfig is the struct that holds the configuration for the daemon's
+// HTTP server (i.e /metrics), the server is disabled if `Listen` is empty.
+// The server is not authenticated, so running checks (and so sending their
+// notifications) with a POST request to the API is only enabled by `ManualRuns`.
+type ServerConfig struct {
+	Listen     string `yaml:"listen"`
+	ManualRuns bool   `yaml:"manual_runs" mapstructure:"manual_runs"`
+}
+
 func init() {
 	viper.SetDefault("log.level", "debug")
+	viper.SetDefault("server.listen", "")
+	viper.SetDefault("server.manual_runs", false)
+	viper.SetDefault("shutdown_timeout", DefaultShutdownTimeout)
+	viper.SetDefault("status_page.title", "Status")
+	viper.SetDefault("status_page.cron", "")
+	viper.SetDefault("status_page.output", "status-page")
+	viper.SetDefault("status_page.incident_days", 14)
+	viper.SetDefault("history.path", "")
+	viper.SetDefault("history.retention", history.DefaultRetention)
+	viper.SetDefault("history.raw_retention", history.DefaultRawRetention)
 }
 
 // New return a new `*Config` with `Checks` slice initialized
 // with 0 checks
 func New() *Config {
 	return &Config{
-		Checks: []*checkInstance{},
+		Checks: []*CheckInstance{},
+	}
+}
+
+// ResolveNotifiers populates `RoutedNotifiers` of every check by the notifier names
+// the check references (or all notifiers if it references none), filtered by the
+// check's severity.
+// It returns an error if a check references a notifier that does not exist.
+func (c *Config) ResolveNotifiers() error {
+	byName := map[string]*NotifierInstance{}
+	for _, notifier := range c.Notifiers {
+		if _, ok := byName[notifier.Name]; ok {
+			return fmt.Errorf("notifier name %q is used more than once", notifier.Name)
+		}
+		byName[notifier.Name] = notifier
+	}
+
+	for _, check := range c.Checks {
+		if check.Severity == "" {
+			check.Severity = DefaultSeverity
+		}
+		candidates := c.Notifiers
+		if len(check.Notifiers) > 0 {
+			candidates = []*NotifierInstance{}
+			for _, name := range check.Notifiers {
+				notifier, ok := byName[name]
+				if !ok {
+					return fmt.Errorf("check %q references notifier %q which does not exist", check.Name, name)
+				}
+				candidates = append(candidates, notifier)
+			}
+		}
+
+		check.RoutedNotifiers = []*NotifierInstance{}
+		for _, notifier := range candidates {
+			if notifier.acceptsSeverity(check.Severity) {
+				check.RoutedNotifiers = append(check.RoutedNotifiers, notifier)
+			}
+		}
 	}
+	return nil
 }
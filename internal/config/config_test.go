@@ -0,0 +1,118 @@
+package config
+
+import (
+	"testing"
+)
+
+func routedNames(check *CheckInstance) []string {
+	names := []string{}
+	for _, notifier := range check.RoutedNotifiers {
+		names = append(names, notifier.Name)
+	}
+	return names
+}
+
+func TestResolveNotifiers(t *testing.T) {
+	cfg := &Config{
+		Notifiers: []*NotifierInstance{
+			{Name: "payments"},
+			{Name: "staging"},
+			{Name: "pager", Severities: []string{"critical"}},
+		},
+		Checks: []*CheckInstance{
+			{Name: "no routing"},
+			{Name: "payments api", Notifiers: []string{"payments", "pager"}},
+			{Name: "staging api", Notifiers: []string{"staging", "pager"}, Severity: "warning"},
+			{Name: "warning everywhere", Severity: "warning"},
+		},
+	}
+	if err := cfg.ResolveNotifiers(); err != nil {
+		t.Fatalf("unexpected error when resolving notifiers: %v", err)
+	}
+
+	expected := map[string][]string{
+		"no routing":         {"payments", "staging", "pager"},
+		"payments api":       {"payments", "pager"},
+		"staging api":        {"staging"},
+		"warning everywhere": {"payments", "staging"},
+	}
+	for _, check := range cfg.Checks {
+		names := routedNames(check)
+		if len(names) != len(expected[check.Name]) {
+			t.Fatalf("check %q: expected notifiers %v, got %v", check.Name, expected[check.Name], names)
+		}
+		for i := range names {
+			if names[i] != expected[check.Name][i] {
+				t.Fatalf("check %q: expected notifiers %v, got %v", check.Name, expected[check.Name], names)
+			}
+		}
+	}
+
+	if cfg.Checks[0].Severity != DefaultSeverity {
+		t.Errorf("expected check without severity to get the default severity, got %q", cfg.Checks[0].Severity)
+	}
+}
+
+func TestResolveNotifiersErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{
+			name: "unknown notifier",
+			cfg: &Config{
+				Notifiers: []*NotifierInstance{{Name: "slack"}},
+				Checks:    []*CheckInstance{{Name: "api", Notifiers: []string{"slak"}}},
+			},
+		},
+		{
+			name: "duplicate notifier names",
+			cfg: &Config{
+				Notifiers: []*NotifierInstance{{Name: "slack"}, {Name: "slack"}},
+				Checks:    []*CheckInstance{{Name: "api"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.cfg.ResolveNotifiers(); err == nil {
+				t.Fatalf("expected resolving notifiers to fail")
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	check := func() *CheckInstance {
+		return &CheckInstance{
+			Name:   "api",
+			Type:   "http",
+			Cron:   "* * * * * *",
+			Labels: map[string]string{"team": "payments"},
+			Config: map[string]interface{}{"url": "http://example.com", "headers": map[string]interface{}{"a": "b"}},
+		}
+	}
+	other := check()
+	other.RoutedNotifiers = []*NotifierInstance{{Name: "slack"}}
+	if !check().Equal(other) {
+		t.Errorf("expected checks with the same configuration to be equal")
+	}
+	other.Config["headers"] = map[string]interface{}{"a": "c"}
+	if check().Equal(other) {
+		t.Errorf("expected checks with different configurations not to be equal")
+	}
+	other = check()
+	other.Cron = "0 * * * * *"
+	if check().Equal(other) {
+		t.Errorf("expected checks with different crons not to be equal")
+	}
+
+	notifier := &NotifierInstance{Name: "slack", Type: "slack", Config: map[string]interface{}{"token": "1234"}}
+	if !notifier.Equal(&NotifierInstance{Name: "slack", Type: "slack", Config: map[string]interface{}{"token": "1234"}}) {
+		t.Errorf("expected notifiers with the same configuration to be equal")
+	}
+	if notifier.Equal(&NotifierInstance{Name: "slack", Type: "slack", Config: map[string]interface{}{"token": "5678"}}) {
+		t.Errorf("expected notifiers with different configurations not to be equal")
+	}
+}
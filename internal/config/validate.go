@@ -0,0 +1,123 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/pkg/checks"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog"
+)
+
+// ValidationError is an error of a single value of the configuration,
+// `Path` is the YAML path of the value, i.e `checks[1].cron`
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate validates the configuration without initializing any of the checks or
+// notifiers, it returns all of the errors it finds rather than just the first one.
+// It validates that names are set and unique, that types exist, cron expressions
+// and the log level are valid, and that every notifier referenced by a check and
+// every check referenced by the status page exists.
+func (c *Config) Validate() []*ValidationError {
+	errs := []*ValidationError{}
+	add := func(path string, err error) {
+		errs = append(errs, &ValidationError{Path: path, Err: err})
+	}
+
+	if c.Log != nil {
+		if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+			add("log.level", err)
+		}
+	}
+
+	notifierNames := map[string]bool{}
+	for i, notifier := range c.Notifiers {
+		path := fmt.Sprintf("notifiers[%d]", i)
+		switch {
+		case notifier.Name == "":
+			add(path+".name", errors.New("name is required"))
+		case notifierNames[notifier.Name]:
+			add(path+".name", fmt.Errorf("notifier name %q is used more than once", notifier.Name))
+		}
+		notifierNames[notifier.Name] = true
+		if _, err := notifiers.FromString(notifier.Type); err != nil {
+			add(path+".type", err)
+		}
+	}
+
+	checkNames := map[string]bool{}
+	for i, check := range c.Checks {
+		path := fmt.Sprintf("checks[%d]", i)
+		switch {
+		case check.Name == "":
+			add(path+".name", errors.New("name is required"))
+		case checkNames[check.Name]:
+			add(path+".name", fmt.Errorf("check name %q is used more than once", check.Name))
+		}
+		checkNames[check.Name] = true
+		if _, err := checks.FromString(check.Type); err != nil {
+			add(path+".type", err)
+		}
+		if err := scheduler.Validate(check.Cron); err != nil {
+			add(path+".cron", fmt.Errorf("invalid cron expression %q: %w", check.Cron, err))
+		}
+		for j, name := range check.Notifiers {
+			if !notifierNames[name] {
+				add(fmt.Sprintf("%s.notifiers[%d]", path, j), fmt.Errorf("notifier %q does not exist", name))
+			}
+		}
+	}
+
+	if c.StatusPage != nil {
+		if c.StatusPage.Cron != "" {
+			if err := scheduler.Validate(c.StatusPage.Cron); err != nil {
+				add("status_page.cron", fmt.Errorf("invalid cron expression %q: %w", c.StatusPage.Cron, err))
+			}
+		}
+		for i, component := range c.StatusPage.Components {
+			for j, name := range component.Checks {
+				if !checkNames[name] {
+					add(fmt.Sprintf("status_page.components[%d].checks[%d]", i, j), fmt.Errorf("check %q does not exist", name))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// DecodeErrors returns the errors of decoding the settings (as read by viper) into
+// a `Config`, with the path of every value that can't be decoded, i.e
+// `shutdown_timeout`. Keys that don't match any field are ignored, as they are
+// by `viper.Unmarshal`.
+func DecodeErrors(settings map[string]interface{}) []*ValidationError {
+	// the decoder config of `viper.Unmarshal`
+	decoderConfig := mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	}
+	errs := []*ValidationError{}
+	for _, err := range mapdecode.FieldErrors(settings, &Config{}, decoderConfig) {
+		if errors.Is(err.Err, mapdecode.ErrUnknownField) {
+			continue
+		}
+		errs = append(errs, &ValidationError{Path: err.Field, Err: err.Err})
+	}
+	return errs
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/amitizle/muffin/internal/statuspage"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := &Config{
+		Log: &LogConfig{Level: "loud"},
+		Notifiers: []*NotifierInstance{
+			{Name: "slack", Type: "slack"},
+			{Name: "slack", Type: "slack"},
+			{Name: "pager", Type: "pagerduty"},
+			{Type: "pigeon"},
+		},
+		Checks: []*CheckInstance{
+			{Name: "api", Type: "http", Cron: "*/10 * * * * *", Notifiers: []string{"slack", "pager"}},
+			{Name: "db", Type: "tcp", Cron: "every minute"},
+			{Name: "api", Type: "htp", Cron: "@every 1m", Notifiers: []string{"pager", "slak"}},
+		},
+		StatusPage: &statuspage.Config{
+			Cron: "* * *",
+			Components: []*statuspage.Component{
+				{Name: "API", Checks: []string{"api", "web"}},
+			},
+		},
+	}
+
+	expected := []string{
+		"log.level",
+		"notifiers[1].name",
+		"notifiers[3].name",
+		"notifiers[3].type",
+		"checks[1].cron",
+		"checks[2].name",
+		"checks[2].type",
+		"checks[2].notifiers[1]",
+		"status_page.cron",
+		"status_page.components[0].checks[1]",
+	}
+	errs := cfg.Validate()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Path != expected[i] {
+			t.Errorf("expected error %d to be of %s, got %s", i, expected[i], err)
+		}
+	}
+
+	valid := &Config{
+		Log:       &LogConfig{Level: "info"},
+		Notifiers: []*NotifierInstance{{Name: "slack", Type: "slack"}},
+		Checks:    []*CheckInstance{{Name: "api", Type: "http", Cron: "@every 1m", Notifiers: []string{"slack"}}},
+	}
+	if errs := valid.Validate(); len(errs) != 0 {
+		t.Errorf("expected a valid config, got %v", errs)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	settings := map[string]interface{}{
+		"shutdown_timeout": "abc",
+		"log":              map[string]interface{}{"level": "info", "colour": true},
+		"checks": []interface{}{
+			map[interface{}]interface{}{"name": "api", "type": "http", "timeout": "5s"},
+			map[interface{}]interface{}{"name": "db", "type": "tcp", "timeout": "soon", "failure_threshold": "many"},
+		},
+	}
+
+	expected := []string{
+		"checks[1].failure_threshold",
+		"checks[1].timeout",
+		"shutdown_timeout",
+	}
+	errs := DecodeErrors(settings)
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Path != expected[i] {
+			t.Errorf("expected error %d to be of %s, got %s", i, expected[i], err)
+		}
+	}
+}
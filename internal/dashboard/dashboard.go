@@ -0,0 +1,29 @@
+package dashboard
+
+import (
+	_ "embed" // the dashboard's page is embedded in the binary
+	"net/http"
+)
+
+// Path is the path the dashboard is served on
+const Path = "/"
+
+//go:embed static/index.html
+var index []byte
+
+// Handler returns the handler of the dashboard, a single self contained page that
+// polls the checks API (see the `api` package) and renders the status of every check
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != Path {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(index)
+	})
+}
@@ -0,0 +1,42 @@
+package dashboard
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "dashboard", method: "GET", path: "/", expectedStatus: 200},
+		{name: "unknown path", method: "GET", path: "/nope", expectedStatus: 404},
+		{name: "POST", method: "POST", path: "/", expectedStatus: 405},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Handler().ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+			if rec.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d", test.expectedStatus, rec.Code)
+			}
+			if test.expectedStatus != 200 {
+				return
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "/api/v1/checks") {
+				t.Errorf("expected the dashboard to poll the checks API")
+			}
+			for _, external := range []string{"src=\"http", "href=\"http", "@import"} {
+				if strings.Contains(body, external) {
+					t.Errorf("expected the dashboard not to load external assets, found %q", external)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Defaults of the history configuration
+const (
+	DefaultRetention    = 90 * 24 * time.Hour
+	DefaultRawRetention = 7 * 24 * time.Hour
+)
+
+var (
+	checksBucket  = []byte("checks")
+	resultsBucket = []byte("results")
+	uptimeBucket  = []byte("uptime")
+	stateKey      = []byte("state")
+)
+
+// Config is the configuration of the history store
+type Config struct {
+	// Path is the path of the database file, history is not kept if it's empty
+	Path string `yaml:"path"`
+	// Retention is how long the hourly uptime buckets of checks are kept
+	Retention time.Duration `yaml:"retention"`
+	// RawRetention is how long every single result is kept, after that results
+	// are only kept downsampled to hourly uptime buckets
+	RawRetention time.Duration `yaml:"raw_retention" mapstructure:"raw_retention"`
+}
+
+// BoltStore is a `monitor.Store` that keeps the history of checks in a bbolt database file.
+// Every check has a bucket with its state, a bucket of results keyed by time and a bucket
+// of uptime counters keyed by the start of the hour.
+type BoltStore struct {
+	db           *bolt.DB
+	retention    time.Duration
+	rawRetention time.Duration
+}
+
+// uptimeCounters is the value of a single uptime bucket
+type uptimeCounters struct {
+	Runs      int `json:"runs"`
+	Successes int `json:"successes"`
+}
+
+// Open opens (or creates) the database file of the store
+func Open(config *Config) (*BoltStore, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("history path is empty")
+	}
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed creating history directory: %w", err)
+		}
+	}
+	db, err := bolt.Open(config.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed opening history %s: %w", config.Path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(checksBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed initializing history %s: %w", config.Path, err)
+	}
+
+	store := &BoltStore{
+		db:           db,
+		retention:    config.Retention,
+		rawRetention: config.RawRetention,
+	}
+	if store.retention <= 0 {
+		store.retention = DefaultRetention
+	}
+	if store.rawRetention <= 0 {
+		store.rawRetention = DefaultRawRetention
+	}
+	return store, nil
+}
+
+// Record records the result in the results bucket of the check, counts it in its
+// hourly uptime bucket and replaces the check's state, all in a single transaction
+func (s *BoltStore) Record(check string, result monitor.Result, checkState monitor.CheckState) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(checksBucket).CreateBucketIfNotExists([]byte(check))
+		if err != nil {
+			return err
+		}
+
+		results, err := b.CreateBucketIfNotExists(resultsBucket)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		if err := results.Put(timeKey(result.Time), value); err != nil {
+			return err
+		}
+
+		uptime, err := b.CreateBucketIfNotExists(uptimeBucket)
+		if err != nil {
+			return err
+		}
+		key := timeKey(result.Time.Truncate(monitor.UptimeResolution))
+		counters := uptimeCounters{}
+		if v := uptime.Get(key); v != nil {
+			if err := json.Unmarshal(v, &counters); err != nil {
+				return err
+			}
+		}
+		counters.Runs++
+		if result.Success {
+			counters.Successes++
+		}
+		if value, err = json.Marshal(counters); err != nil {
+			return err
+		}
+		if err := uptime.Put(key, value); err != nil {
+			return err
+		}
+
+		if value, err = json.Marshal(checkState); err != nil {
+			return err
+		}
+		return b.Put(stateKey, value)
+	})
+}
+
+// Results returns up to `limit` of the most recent results of the check, oldest first
+func (s *BoltStore) Results(check string, limit int) ([]monitor.Result, error) {
+	results := []monitor.Result{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := checkBucket(tx, check, resultsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(results) < limit); k, v = c.Prev() {
+			result := monitor.Result{}
+			if err := json.Unmarshal(v, &result); err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed reading history of %q: %w", check, err)
+	}
+	// the results were read newest first
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+	return results, nil
+}
+
+// Uptime returns the uptime buckets of the check that started after `since`, oldest first
+func (s *BoltStore) Uptime(check string, since time.Time) ([]monitor.UptimeBucket, error) {
+	buckets := []monitor.UptimeBucket{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := checkBucket(tx, check, uptimeBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(timeKey(since)); k != nil; k, v = c.Next() {
+			start := keyTime(k)
+			if !start.After(since) {
+				continue
+			}
+			counters := uptimeCounters{}
+			if err := json.Unmarshal(v, &counters); err != nil {
+				return err
+			}
+			buckets = append(buckets, monitor.UptimeBucket{Start: start, Runs: counters.Runs, Successes: counters.Successes})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed reading uptime of %q: %w", check, err)
+	}
+	return buckets, nil
+}
+
+// State returns the last recorded state of the check, or nil if it was never recorded
+func (s *BoltStore) State(check string) (*monitor.CheckState, error) {
+	var checkState *monitor.CheckState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(checksBucket).Bucket([]byte(check))
+		if b == nil {
+			return nil
+		}
+		v := b.Get(stateKey)
+		if v == nil {
+			return nil
+		}
+		checkState = &monitor.CheckState{}
+		return json.Unmarshal(v, checkState)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed reading state of %q: %w", check, err)
+	}
+	return checkState, nil
+}
+
+// Prune removes results that are older than the raw retention and uptime buckets
+// that are older than the retention. Checks that are not in `configured` (i.e were
+// removed from the config) are removed once they have no data left, the state of
+// configured checks is kept so they resume in it after a restart.
+func (s *BoltStore) Prune(now time.Time, configured []string) error {
+	keep := map[string]bool{}
+	for _, name := range configured {
+		keep[name] = true
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		checks := tx.Bucket(checksBucket)
+		empty := [][]byte{}
+		err := checks.ForEach(func(name, v []byte) error {
+			b := checks.Bucket(name)
+			if b == nil {
+				return nil
+			}
+			results, err := pruneBucket(b.Bucket(resultsBucket), now.Add(-s.rawRetention))
+			if err != nil {
+				return err
+			}
+			buckets, err := pruneBucket(b.Bucket(uptimeBucket), now.Add(-s.retention))
+			if err != nil {
+				return err
+			}
+			if !results && !buckets && !keep[string(name)] {
+				empty = append(empty, append([]byte{}, name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range empty {
+			if err := checks.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// pruneBucket deletes the keys of a time keyed bucket that are before `before`,
+// it returns true if there are keys left in the bucket
+func pruneBucket(b *bolt.Bucket, before time.Time) (bool, error) {
+	if b == nil {
+		return false, nil
+	}
+	c := b.Cursor()
+	k, _ := c.First()
+	for ; k != nil && keyTime(k).Before(before); k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return false, err
+		}
+	}
+	return k != nil, nil
+}
+
+func checkBucket(tx *bolt.Tx, check string, name []byte) *bolt.Bucket {
+	b := tx.Bucket(checksBucket).Bucket([]byte(check))
+	if b == nil {
+		return nil
+	}
+	return b.Bucket(name)
+}
+
+// timeKey returns a key that sorts by time, times before
+// the unix epoch (i.e the zero time) are all the first key
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return key
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
@@ -0,0 +1,201 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/rs/zerolog"
+)
+
+func openTestStore(t *testing.T, config *Config) (*BoltStore, func()) {
+	dir, err := ioutil.TempDir("", "muffin-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Path == "" {
+		config.Path = filepath.Join(dir, "history", "muffin.db")
+	}
+	store, err := Open(config)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed opening store: %v", err)
+	}
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	store, cleanup := openTestStore(t, &Config{})
+	defer cleanup()
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		// a run every 40 minutes, every third run fails
+		result := monitor.Result{Time: start.Add(time.Duration(i) * 40 * time.Minute), Success: i%3 != 2, Latency: time.Duration(i) * time.Millisecond}
+		if !result.Success {
+			result.Error = "connection refused"
+		}
+		checkState := monitor.CheckState{Tracker: state.Snapshot{State: state.OK, Since: start, Streak: i % 3}}
+		if err := store.Record("api", result, checkState); err != nil {
+			t.Fatalf("failed recording result: %v", err)
+		}
+	}
+
+	results, err := store.Results("api", 4)
+	if err != nil {
+		t.Fatalf("failed reading results: %v", err)
+	}
+	if len(results) != 4 || !results[0].Time.Equal(start.Add(80*time.Minute)) || !results[3].Time.Equal(start.Add(200*time.Minute)) {
+		t.Fatalf("expected the 4 most recent results oldest first, got %+v", results)
+	}
+	if results[0].Success || results[0].Error != "connection refused" || results[3].Latency != 5*time.Millisecond {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	buckets, err := store.Uptime("api", start.Add(-time.Second))
+	if err != nil {
+		t.Fatalf("failed reading uptime: %v", err)
+	}
+	expected := []monitor.UptimeBucket{
+		{Start: start, Runs: 2, Successes: 2},
+		{Start: start.Add(time.Hour), Runs: 1, Successes: 0},
+		{Start: start.Add(2 * time.Hour), Runs: 2, Successes: 2},
+		{Start: start.Add(3 * time.Hour), Runs: 1, Successes: 0},
+	}
+	if len(buckets) != len(expected) {
+		t.Fatalf("expected %d uptime buckets, got %+v", len(expected), buckets)
+	}
+	for i := range expected {
+		if !buckets[i].Start.Equal(expected[i].Start) || buckets[i].Runs != expected[i].Runs || buckets[i].Successes != expected[i].Successes {
+			t.Errorf("expected uptime bucket %+v, got %+v", expected[i], buckets[i])
+		}
+	}
+
+	checkState, err := store.State("api")
+	if err != nil || checkState == nil {
+		t.Fatalf("failed reading state: %v", err)
+	}
+	if checkState.Tracker.State != state.OK || checkState.Tracker.Streak != 2 || !checkState.Tracker.Since.Equal(start) {
+		t.Errorf("unexpected state: %+v", checkState)
+	}
+	if checkState, err := store.State("nope"); checkState != nil || err != nil {
+		t.Errorf("expected no state of an unknown check, got %+v (%v)", checkState, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	store, cleanup := openTestStore(t, &Config{Retention: 48 * time.Hour, RawRetention: 2 * time.Hour})
+	defer cleanup()
+
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, age := range []time.Duration{72 * time.Hour, 24 * time.Hour, 3 * time.Hour, time.Hour} {
+		store.Record("api", monitor.Result{Time: now.Add(-age), Success: true}, monitor.CheckState{})
+	}
+	store.Record("removed check", monitor.Result{Time: now.Add(-72 * time.Hour)}, monitor.CheckState{})
+	failing := monitor.CheckState{Tracker: state.Snapshot{State: state.Failing}}
+	store.Record("quiet check", monitor.Result{Time: now.Add(-72 * time.Hour)}, failing)
+
+	if err := store.Prune(now, []string{"api", "quiet check"}); err != nil {
+		t.Fatalf("failed pruning: %v", err)
+	}
+	results, _ := store.Results("api", 0)
+	if len(results) != 1 || !results[0].Time.Equal(now.Add(-time.Hour)) {
+		t.Errorf("expected only the results of the raw retention to be kept, got %+v", results)
+	}
+	buckets, _ := store.Uptime("api", time.Time{})
+	if len(buckets) != 3 {
+		t.Errorf("expected the uptime buckets of the retention to be kept, got %+v", buckets)
+	}
+	if checkState, _ := store.State("removed check"); checkState != nil {
+		t.Errorf("expected a check without data to be removed, got %+v", checkState)
+	}
+	checkState, _ := store.State("quiet check")
+	if checkState == nil || checkState.Tracker.State != state.Failing {
+		t.Errorf("expected the state of a configured check without data to be kept, got %+v", checkState)
+	}
+}
+
+type fakeCheck struct {
+	err error
+}
+
+func (c *fakeCheck) Initialize(context.Context) error       { return nil }
+func (c *fakeCheck) Configure(map[string]interface{}) error { return nil }
+func (c *fakeCheck) Run(context.Context) ([]byte, error)    { return []byte{}, c.err }
+
+type countingNotifier struct {
+	events int
+}
+
+func (n *countingNotifier) Initialize(context.Context) error       { return nil }
+func (n *countingNotifier) Configure(map[string]interface{}) error { return nil }
+func (n *countingNotifier) Notify(notifiers.Event) error {
+	n.events++
+	return nil
+}
+
+func TestRestoreMonitor(t *testing.T) {
+	config := &Config{}
+	store, cleanup := openTestStore(t, config)
+	defer cleanup()
+
+	newMonitor := func(store monitor.Store, notifier *countingNotifier) *monitor.Monitor {
+		m := monitor.New(scheduler.New(), nil)
+		m.SetStore(store)
+		err := m.Add(&monitor.Check{
+			Name:      "api",
+			Type:      "fake",
+			Cron:      "0 0 * * * *",
+			Check:     &fakeCheck{err: errors.New("connection refused")},
+			Notifiers: []*monitor.Notifier{{Name: "counting", Notifier: notifier}},
+			Logger:    zerolog.Nop(),
+		})
+		if err != nil {
+			t.Fatalf("failed adding check: %v", err)
+		}
+		return m
+	}
+
+	notifier := &countingNotifier{}
+	m := newMonitor(store, notifier)
+	m.Run("api")
+	m.Run("api")
+	if notifier.events != 1 {
+		t.Fatalf("expected a single failure notification, got %d", notifier.events)
+	}
+	store.Close()
+
+	// restart
+	store, err := Open(config)
+	if err != nil {
+		t.Fatalf("failed reopening store: %v", err)
+	}
+	notifier = &countingNotifier{}
+	m = newMonitor(store, notifier)
+	status, _ := m.Check("api")
+	if status.State != state.Failing || status.LastResult == nil || status.LastFailure == nil || status.Uptime[0].Runs != 2 {
+		t.Fatalf("expected the check's state to be restored, got %+v", status)
+	}
+	if incidents := m.Incidents(time.Time{}); len(incidents) != 1 || !incidents[0].Ongoing() {
+		t.Fatalf("expected the ongoing incident to be restored, got %+v", incidents)
+	}
+	m.Run("api")
+	if notifier.events != 0 {
+		t.Fatalf("expected no notification after a restart for a check that was already failing, got %d", notifier.events)
+	}
+	history, _ := m.History("api", 0)
+	if len(history) != 3 {
+		t.Fatalf("expected the history to be restored, got %d results", len(history))
+	}
+}
@@ -0,0 +1,157 @@
+package mapdecode
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// FieldError is an error of a single field of a configuration map, `Field` is
+// the path of the field within the map, i.e `basic_auth.password`.
+// Its message is the message of the wrapped error, so it reads the same in logs.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// NewFieldError returns a FieldError of the given field
+func NewFieldError(field string, err error) error {
+	return &FieldError{Field: field, Err: err}
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Errors are the errors of decoding a configuration map, one for every field that
+// could not be decoded or is unknown
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", err.Field, err.Err)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ErrUnknownField is the error of a key that doesn't match any field of the output
+var ErrUnknownField = errors.New("unknown field")
+
+// FieldErrors returns the errors of decoding the input into the output's type with the
+// given decoder config (its `Result` is ignored). The fields of the output are walked by
+// their `mapstructure` names and every field is decoded on its own, so each error has
+// the path of its field (i.e `checks[1].timeout`). Keys that don't match any field are
+// returned as unknown fields.
+func FieldErrors(input interface{}, output interface{}, config mapstructure.DecoderConfig) Errors {
+	errs := Errors{}
+	walkFields("", input, reflect.TypeOf(output), config, &errs)
+	return errs
+}
+
+func walkFields(path string, input interface{}, outputType reflect.Type, config mapstructure.DecoderConfig, errs *Errors) {
+	for outputType.Kind() == reflect.Ptr {
+		outputType = outputType.Elem()
+	}
+	inputValue := reflect.ValueOf(input)
+	switch {
+	case input == nil || outputType.Kind() == reflect.Interface:
+		return
+	case outputType.Kind() == reflect.Struct && inputValue.Kind() == reflect.Map:
+		keys := mapKeys(inputValue)
+		for _, key := range keys {
+			value := inputValue.MapIndex(reflect.ValueOf(key).Convert(inputValue.Type().Key())).Interface()
+			field, ok := structField(outputType, key)
+			if !ok {
+				*errs = append(*errs, &FieldError{Field: joinPath(path, key), Err: ErrUnknownField})
+				continue
+			}
+			walkFields(joinPath(path, key), value, field.Type, config, errs)
+		}
+	case outputType.Kind() == reflect.Slice && inputValue.Kind() == reflect.Slice:
+		for i := 0; i < inputValue.Len(); i++ {
+			walkFields(fmt.Sprintf("%s[%d]", path, i), inputValue.Index(i).Interface(), outputType.Elem(), config, errs)
+		}
+	case outputType.Kind() == reflect.Map && inputValue.Kind() == reflect.Map:
+		for _, key := range mapKeys(inputValue) {
+			value := inputValue.MapIndex(reflect.ValueOf(key).Convert(inputValue.Type().Key())).Interface()
+			walkFields(fmt.Sprintf("%s[%s]", path, key), value, outputType.Elem(), config, errs)
+		}
+	default:
+		if err := decodeField(path, input, outputType, config); err != nil {
+			*errs = append(*errs, &FieldError{Field: path, Err: err})
+		}
+	}
+}
+
+// decodeField decodes the value of a single field, the field is decoded as a
+// struct field named by the last element of its path so errors read as they do
+// when the whole input is decoded
+func decodeField(path string, input interface{}, outputType reflect.Type, config mapstructure.DecoderConfig) error {
+	name := path[strings.LastIndexAny(path, ".")+1:]
+	fieldType := reflect.StructOf([]reflect.StructField{{
+		Name: "Field",
+		Type: outputType,
+		Tag:  reflect.StructTag(fmt.Sprintf(`mapstructure:"%s"`, name)),
+	}})
+	config.Result = reflect.New(fieldType).Interface()
+	config.Metadata = nil
+	decoder, err := mapstructure.NewDecoder(&config)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(map[string]interface{}{name: input})
+	if decodeErr, ok := err.(*mapstructure.Error); ok && len(decodeErr.Errors) == 1 {
+		return errors.New(decodeErr.Errors[0])
+	}
+	return err
+}
+
+// structField returns the field of the struct type that is decoded from the given key,
+// matching it the way `mapstructure` does
+func structField(structType reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]; tag != "" {
+			name = tag
+		}
+		if name == "-" {
+			continue
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// mapKeys returns the keys of a map with string keys, sorted
+func mapKeys(m reflect.Value) []string {
+	keys := []string{}
+	for _, key := range m.MapKeys() {
+		if s, ok := key.Interface().(string); ok {
+			keys = append(keys, s)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
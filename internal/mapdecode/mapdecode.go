@@ -0,0 +1,36 @@
+package mapdecode
+
+import (
+	"github.com/amitizle/muffin/internal/secret"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Decode decodes a configuration map (as given to checks and notifiers) into
+// the output struct using `mapstructure`.
+// On top of the default `mapstructure` behaviour it decodes durations
+// from strings (i.e "10s") and secrets from plain strings, and fails on keys
+// that don't match any field. Decoding errors are returned as `Errors`, with
+// the field each of them belongs to.
+func Decode(input interface{}, output interface{}) error {
+	config := mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			secret.DecodeHook(),
+		),
+	}
+	metadata := &mapstructure.Metadata{}
+	decoderConfig := config
+	decoderConfig.Result, decoderConfig.Metadata = output, metadata
+	decoder, err := mapstructure.NewDecoder(&decoderConfig)
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(input)
+	if err == nil && len(metadata.Unused) == 0 {
+		return nil
+	}
+	if errs := FieldErrors(input, output, config); len(errs) > 0 {
+		return errs
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package mapdecode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/secret"
+)
+
+type testAuth struct {
+	Username string         `mapstructure:"username"`
+	Password *secret.Secret `mapstructure:"password"`
+}
+
+type testConfig struct {
+	Host    string            `mapstructure:"host"`
+	Port    int               `mapstructure:"port"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+	Auth    *testAuth         `mapstructure:"auth"`
+	To      []string          `mapstructure:"to"`
+	Headers map[string]int    `mapstructure:"headers"`
+	Labels  map[string]string `mapstructure:"labels"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          map[string]interface{}
+		expectedFields []string
+	}{
+		{
+			name:  "valid",
+			input: map[string]interface{}{"host": "db", "port": 5432, "timeout": "5s", "auth": map[string]interface{}{"username": "muffin", "password": "s3cr3t"}},
+		},
+		{
+			name:           "invalid values",
+			input:          map[string]interface{}{"host": []string{"db"}, "port": "x", "timeout": "soon"},
+			expectedFields: []string{"host", "port", "timeout"},
+		},
+		{
+			name:           "nested field",
+			input:          map[string]interface{}{"auth": map[string]interface{}{"username": "muffin", "password": []int{1}}},
+			expectedFields: []string{"auth.password"},
+		},
+		{
+			name:           "slice and map elements",
+			input:          map[string]interface{}{"to": []interface{}{"ops", []int{1}}, "headers": map[string]interface{}{"X-Retries": "many"}},
+			expectedFields: []string{"headers[X-Retries]", "to[1]"},
+		},
+		{
+			name:           "unknown key",
+			input:          map[string]interface{}{"host": "db", "hots": "db"},
+			expectedFields: []string{"hots"},
+		},
+		{
+			name:           "unknown nested key",
+			input:          map[string]interface{}{"auth": map[string]interface{}{"usrname": "muffin"}},
+			expectedFields: []string{"auth.usrname"},
+		},
+		{
+			name:           "quoted value",
+			input:          map[string]interface{}{"labels": map[string]interface{}{"team": "'ops'"}, "port": "'80'"},
+			expectedFields: []string{"port"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Decode(test.input, &testConfig{})
+			if len(test.expectedFields) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error decoding: %v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected field errors, got %v", err)
+			}
+			if len(errs) != len(test.expectedFields) {
+				t.Fatalf("expected errors of %v, got %v", test.expectedFields, errs)
+			}
+			for i, field := range test.expectedFields {
+				if errs[i].Field != field {
+					t.Errorf("expected error %d to be of %s, got %s", i, field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
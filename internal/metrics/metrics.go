@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "muffin"
+
+var checkLabels = []string{"check", "type"}
+
+// Metrics holds the prometheus collectors of the checks and the scheduler.
+// All of the check metrics are labeled with the check's name and type.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	up                 *prometheus.GaugeVec
+	runDuration        *prometheus.HistogramVec
+	runs               *prometheus.CounterVec
+	failures           *prometheus.CounterVec
+	lastSuccess        *prometheus.GaugeVec
+	notificationErrors *prometheus.CounterVec
+
+	// failedNotifiers are the notifiers that failed by check name and type,
+	// so the notification errors of a check can be removed with the check
+	mu              sync.Mutex
+	failedNotifiers map[[2]string]map[string]bool
+}
+
+// New returns a new `Metrics` with all of its collectors registered
+// to a dedicated registry (along with the go and process collectors)
+func New() *Metrics {
+	m := &Metrics{
+		registry:        prometheus.NewRegistry(),
+		failedNotifiers: map[[2]string]map[string]bool{},
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "check_up",
+			Help:      "Whether the check is up (1) or down (0), after applying the check's thresholds.",
+		}, checkLabels),
+		runDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "check_run_duration_seconds",
+			Help:      "Duration of check runs.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, checkLabels),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "check_runs_total",
+			Help:      "Total number of check runs.",
+		}, checkLabels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "check_failures_total",
+			Help:      "Total number of failed check runs.",
+		}, checkLabels),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "check_last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful check run.",
+		}, checkLabels),
+		notificationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notification_errors_total",
+			Help:      "Total number of notifications that failed to be sent.",
+		}, append(checkLabels, "notifier")),
+	}
+	m.registry.MustRegister(
+		m.up,
+		m.runDuration,
+		m.runs,
+		m.failures,
+		m.lastSuccess,
+		m.notificationErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// ObserveRun records a single run of a check
+func (m *Metrics) ObserveRun(name, checkType string, duration time.Duration, success bool, at time.Time) {
+	m.runs.WithLabelValues(name, checkType).Inc()
+	m.runDuration.WithLabelValues(name, checkType).Observe(duration.Seconds())
+	if success {
+		m.lastSuccess.WithLabelValues(name, checkType).Set(float64(at.Unix()))
+	} else {
+		m.failures.WithLabelValues(name, checkType).Inc()
+	}
+}
+
+// SetState sets the up gauge of a check by its state, checks in
+// the `Unknown` state are not exported
+func (m *Metrics) SetState(name, checkType string, s state.State) {
+	switch s {
+	case state.OK:
+		m.up.WithLabelValues(name, checkType).Set(1)
+	case state.Failing:
+		m.up.WithLabelValues(name, checkType).Set(0)
+	}
+}
+
+// NotificationFailed records a notification of a check that failed to be sent
+func (m *Metrics) NotificationFailed(name, checkType, notifier string) {
+	m.notificationErrors.WithLabelValues(name, checkType, notifier).Inc()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{name, checkType}
+	if m.failedNotifiers[key] == nil {
+		m.failedNotifiers[key] = map[string]bool{}
+	}
+	m.failedNotifiers[key][notifier] = true
+}
+
+// RemoveCheck removes all of the series of a check, i.e when it's removed from the configuration
+func (m *Metrics) RemoveCheck(name, checkType string) {
+	labels := prometheus.Labels{"check": name, "type": checkType}
+	m.up.Delete(labels)
+	m.runDuration.Delete(labels)
+	m.runs.Delete(labels)
+	m.failures.Delete(labels)
+	m.lastSuccess.Delete(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{name, checkType}
+	for notifier := range m.failedNotifiers[key] {
+		m.notificationErrors.DeleteLabelValues(name, checkType, notifier)
+	}
+	delete(m.failedNotifiers, key)
+}
+
+// RegisterScheduler registers gauges of the scheduler's health
+func (m *Metrics) RegisterScheduler(s *scheduler.Scheduler) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "tasks",
+			Help:      "Number of scheduled tasks.",
+		}, func() float64 { return float64(s.Tasks()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "running_tasks",
+			Help:      "Number of tasks that are currently running.",
+		}, func() float64 { return float64(s.Running()) }),
+	)
+}
+
+// Handler returns the HTTP handler that exposes the metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
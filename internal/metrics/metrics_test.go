@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRun(t *testing.T) {
+	m := New()
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.ObserveRun("api", "http", 100*time.Millisecond, true, at)
+	m.ObserveRun("api", "http", 200*time.Millisecond, false, at.Add(time.Minute))
+	m.ObserveRun("api", "http", 300*time.Millisecond, false, at.Add(2*time.Minute))
+
+	if runs := testutil.ToFloat64(m.runs.WithLabelValues("api", "http")); runs != 3 {
+		t.Errorf("expected 3 runs, got %v", runs)
+	}
+	if failures := testutil.ToFloat64(m.failures.WithLabelValues("api", "http")); failures != 2 {
+		t.Errorf("expected 2 failures, got %v", failures)
+	}
+	if lastSuccess := testutil.ToFloat64(m.lastSuccess.WithLabelValues("api", "http")); lastSuccess != float64(at.Unix()) {
+		t.Errorf("expected last success at %d, got %v", at.Unix(), lastSuccess)
+	}
+}
+
+func TestSetState(t *testing.T) {
+	m := New()
+	m.SetState("api", "http", state.Unknown)
+	if count := testutil.CollectAndCount(m.up); count != 0 {
+		t.Fatalf("expected checks in unknown state not to be exported, got %d series", count)
+	}
+	m.SetState("api", "http", state.Failing)
+	if up := testutil.ToFloat64(m.up.WithLabelValues("api", "http")); up != 0 {
+		t.Errorf("expected failing check to be down, got %v", up)
+	}
+	m.SetState("api", "http", state.OK)
+	if up := testutil.ToFloat64(m.up.WithLabelValues("api", "http")); up != 1 {
+		t.Errorf("expected ok check to be up, got %v", up)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	s := scheduler.New()
+	s.NewTask("0 0 * * * *", func() {})
+	m.RegisterScheduler(s)
+	m.ObserveRun("api", "http", time.Second, false, time.Now())
+	m.SetState("api", "http", state.Failing)
+	m.NotificationFailed("api", "http", "slack")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	for _, expected := range []string{
+		`muffin_check_up{check="api",type="http"} 0`,
+		`muffin_check_runs_total{check="api",type="http"} 1`,
+		`muffin_check_failures_total{check="api",type="http"} 1`,
+		`muffin_check_run_duration_seconds_count{check="api",type="http"} 1`,
+		`muffin_notification_errors_total{check="api",notifier="slack",type="http"} 1`,
+		`muffin_scheduler_tasks 1`,
+		`muffin_scheduler_running_tasks 0`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected metrics to contain %q", expected)
+		}
+	}
+}
+
+func TestRemoveCheck(t *testing.T) {
+	m := New()
+	m.ObserveRun("api", "http", time.Second, false, time.Now())
+	m.SetState("api", "http", state.Failing)
+	m.NotificationFailed("api", "http", "slack")
+	m.ObserveRun("web", "http", time.Second, true, time.Now())
+
+	m.RemoveCheck("api", "http")
+	if count := testutil.CollectAndCount(m.runs); count != 1 {
+		t.Errorf("expected only the runs of the remaining check, got %d series", count)
+	}
+	for _, c := range []prometheus.Collector{m.up, m.failures, m.notificationErrors} {
+		if count := testutil.CollectAndCount(c); count != 0 {
+			t.Errorf("expected the series of the removed check to be deleted, got %d series", count)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"sort"
+	"time"
+)
+
+// maxIncidents is the number of incidents that are kept in memory for every check
+const maxIncidents = 100
+
+// Incident is a period in which a check was failing
+type Incident struct {
+	Check string
+	Start time.Time
+	// End is the time the check recovered, it's zero while the incident is ongoing
+	End time.Time
+	// Error is the error of the run that opened the incident
+	Error string
+}
+
+// Ongoing returns true if the check did not recover from the incident yet
+func (i Incident) Ongoing() bool {
+	return i.End.IsZero()
+}
+
+// Duration returns the duration of the incident, up to `now` if it's ongoing
+func (i Incident) Duration(now time.Time) time.Duration {
+	if i.Ongoing() {
+		return now.Sub(i.Start)
+	}
+	return i.End.Sub(i.Start)
+}
+
+// Incidents returns the incidents of all checks that started after `since`,
+// newest first
+func (m *Monitor) Incidents(since time.Time) []Incident {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	incidents := []Incident{}
+	for _, check := range m.checks {
+		check.mu.Lock()
+		for _, incident := range check.incidents {
+			if incident.Start.After(since) {
+				incidents = append(incidents, incident)
+			}
+		}
+		check.mu.Unlock()
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].Start.After(incidents[j].Start)
+	})
+	return incidents
+}
+
+// openIncident records the beginning of an incident, dropping the oldest
+// incident of the check if it has too many
+func (check *Check) openIncident(start time.Time, err string) {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	check.incidents = append(check.incidents, Incident{Check: check.Name, Start: start, Error: err})
+	if len(check.incidents) > maxIncidents {
+		check.incidents = append([]Incident{}, check.incidents[len(check.incidents)-maxIncidents:]...)
+	}
+}
+
+// closeIncident records the end of the check's ongoing incident
+func (check *Check) closeIncident(end time.Time) {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	if n := len(check.incidents); n > 0 && check.incidents[n-1].Ongoing() {
+		check.incidents[n-1].End = end
+	}
+}
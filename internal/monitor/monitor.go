@@ -0,0 +1,453 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/metrics"
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/internal/truncate"
+	"github.com/amitizle/muffin/pkg/checks"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/rs/zerolog"
+)
+
+// DefaultHistorySize is the number of results that are kept in memory for every check
+const DefaultHistorySize = 100
+
+// maxOutputExcerpt is the maximum number of bytes of a check's output
+// that are kept in results and sent to notifiers
+const maxOutputExcerpt = 1024
+
+// ErrCheckNotFound is returned when a check does not exist in the monitor
+var ErrCheckNotFound = errors.New("check not found")
+
+// Notifier is a notifier a check sends its events to
+type Notifier struct {
+	Name     string
+	Notifier notifiers.Notifier
+}
+
+// Result is the result of a single run of a check
+type Result struct {
+	Time     time.Time
+	Success  bool
+	Latency  time.Duration
+	Error    string
+	TimedOut bool
+	Output   string
+}
+
+// Check is a configured check and everything the monitor knows about it
+type Check struct {
+	Name             string
+	Type             string
+	Cron             string
+	Labels           map[string]string
+	Severity         string
+	Timeout          time.Duration
+	FailureThreshold int
+	SuccessThreshold int
+	Check            checks.Check
+	Notifiers        []*Notifier
+	Logger           zerolog.Logger
+
+	// private fields
+	task    scheduler.TaskID
+	tracker *state.Tracker
+	// runMu makes sure that a check doesn't run concurrently,
+	// i.e when it's triggered while a scheduled run is in progress
+	runMu     sync.Mutex
+	mu        sync.Mutex
+	history   []Result
+	uptime    uptimeCounter
+	incidents []Incident
+	// lastFailure is the most recent failed result, it's kept
+	// after the check recovers and its failure left the history
+	lastFailure *Result
+}
+
+// Status is a snapshot of a check's state
+type Status struct {
+	Name       string
+	Type       string
+	Target     string
+	Labels     map[string]string
+	Severity   string
+	State      state.State
+	Since      time.Time
+	LastResult *Result
+	// LastFailure is the most recent failed result, it's nil if the check never failed
+	LastFailure *Result
+	NextRun     time.Time
+	// Uptime is the check's uptime over each of the `UptimeWindows`
+	Uptime []Uptime
+}
+
+// Monitor runs checks on their schedule, tracks their state and results
+// and notifies about state changes. It is safe for concurrent use.
+type Monitor struct {
+	scheduler   *scheduler.Scheduler
+	metrics     *metrics.Metrics
+	historySize int
+
+	mu     sync.RWMutex
+	store  Store
+	checks []*Check
+	byName map[string]*Check
+}
+
+// New returns a new monitor that schedules checks on the given scheduler
+// and reports them to the given metrics (which may be nil)
+func New(s *scheduler.Scheduler, m *metrics.Metrics) *Monitor {
+	return &Monitor{
+		scheduler:   s,
+		metrics:     m,
+		historySize: DefaultHistorySize,
+		checks:      []*Check{},
+		byName:      map[string]*Check{},
+	}
+}
+
+// Add adds an initialized and configured check to the monitor and schedules it.
+// It returns an error if a check with the same name already exists or the cron
+// expression is invalid.
+func (m *Monitor) Add(check *Check) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byName[check.Name]; ok {
+		return fmt.Errorf("check name %q is used more than once", check.Name)
+	}
+	check.tracker = state.NewTracker(check.FailureThreshold, check.SuccessThreshold)
+	check.history = []Result{}
+	if m.store != nil {
+		restored, err := m.restore(check)
+		if err != nil {
+			return fmt.Errorf("failed restoring check %q: %w", check.Name, err)
+		}
+		if restored {
+			check.Logger.Info().Str("state", check.tracker.State().String()).Msg("restored check state")
+		}
+	}
+	task, err := m.scheduler.AddTask(check.Cron, func() {
+		m.run(check)
+	})
+	if err != nil {
+		return fmt.Errorf("check %q has an invalid cron expression %q: %w", check.Name, check.Cron, err)
+	}
+	check.task = task
+	m.checks = append(m.checks, check)
+	m.byName[check.Name] = check
+	return nil
+}
+
+// Remove unschedules the check with the given name and removes it from the monitor,
+// a run of the check that is in progress is not stopped
+func (m *Monitor) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	check, ok := m.byName[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrCheckNotFound, name)
+	}
+	m.scheduler.RemoveTask(check.task)
+	for i, c := range m.checks {
+		if c == check {
+			m.checks = append(m.checks[:i:i], m.checks[i+1:]...)
+			break
+		}
+	}
+	delete(m.byName, name)
+	if m.metrics != nil {
+		m.metrics.RemoveCheck(check.Name, check.Type)
+	}
+	return nil
+}
+
+// Update replaces the check that has the same name as the given check and reschedules it.
+// The state, history, uptime and incidents of the replaced check are kept. If a run of
+// the replaced check is in progress, Update waits for it to finish.
+func (m *Monitor) Update(check *Check) error {
+	m.mu.Lock()
+	old, ok := m.byName[check.Name]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrCheckNotFound, check.Name)
+	}
+	// the new check must not run before it's fully set up
+	check.runMu.Lock()
+	defer check.runMu.Unlock()
+	task, err := m.scheduler.AddTask(check.Cron, func() {
+		m.run(check)
+	})
+	if err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("check %q has an invalid cron expression %q: %w", check.Name, check.Cron, err)
+	}
+	m.scheduler.RemoveTask(old.task)
+	check.task = task
+	m.mu.Unlock()
+
+	// a run of the old check that is in progress needs the monitor's lock to finish,
+	// so it's waited for without holding it
+	old.runMu.Lock()
+	defer old.runMu.Unlock()
+	check.tracker = state.NewTracker(check.FailureThreshold, check.SuccessThreshold)
+	check.tracker.Restore(old.tracker.Snapshot())
+	old.mu.Lock()
+	check.history = old.history
+	check.uptime = old.uptime
+	check.incidents = old.incidents
+	check.lastFailure = old.lastFailure
+	old.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.byName[check.Name] != old {
+		m.scheduler.RemoveTask(task)
+		return fmt.Errorf("check %q was removed or replaced while it was updated", check.Name)
+	}
+	for i, c := range m.checks {
+		if c == old {
+			m.checks[i] = check
+		}
+	}
+	m.byName[check.Name] = check
+	if m.metrics != nil && old.Type != check.Type {
+		m.metrics.RemoveCheck(old.Name, old.Type)
+	}
+	return nil
+}
+
+// SetNotifiers replaces the notifiers of the check with the given name
+func (m *Monitor) SetNotifiers(name string, checkNotifiers []*Notifier) error {
+	check, err := m.get(name)
+	if err != nil {
+		return err
+	}
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	check.Notifiers = checkNotifiers
+	return nil
+}
+
+// Checks returns the statuses of all checks, in the order they were added
+func (m *Monitor) Checks() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	statuses := make([]Status, 0, len(m.checks))
+	for _, check := range m.checks {
+		statuses = append(statuses, m.status(check))
+	}
+	return statuses
+}
+
+// Check returns the status of the check with the given name
+func (m *Monitor) Check(name string) (Status, error) {
+	check, err := m.get(name)
+	if err != nil {
+		return Status{}, err
+	}
+	return m.status(check), nil
+}
+
+// History returns up to `limit` of the most recent results of the given check,
+// oldest first. All of the results that are kept are returned if `limit` is
+// not positive.
+func (m *Monitor) History(name string, limit int) ([]Result, error) {
+	check, err := m.get(name)
+	if err != nil {
+		return nil, err
+	}
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	history := check.history
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return append([]Result{}, history...), nil
+}
+
+// Run runs the given check immediately, outside of its schedule, and returns its result.
+// The result is handled just like a scheduled run's result (state, notifications etc.).
+func (m *Monitor) Run(name string) (Result, error) {
+	check, err := m.get(name)
+	if err != nil {
+		return Result{}, err
+	}
+	return m.run(check), nil
+}
+
+func (m *Monitor) get(name string) (*Check, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	check, ok := m.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCheckNotFound, name)
+	}
+	return check, nil
+}
+
+func (m *Monitor) status(check *Check) Status {
+	status := Status{
+		Name:     check.Name,
+		Type:     check.Type,
+		Labels:   check.Labels,
+		Severity: check.Severity,
+		State:    check.tracker.State(),
+		Since:    check.tracker.Since(),
+		NextRun:  m.scheduler.Next(check.task),
+	}
+	if targeter, ok := check.Check.(checks.Targeter); ok {
+		status.Target = targeter.Target()
+	}
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	if len(check.history) > 0 {
+		last := check.history[len(check.history)-1]
+		status.LastResult = &last
+	}
+	if check.lastFailure != nil {
+		lastFailure := *check.lastFailure
+		status.LastFailure = &lastFailure
+	}
+	now := time.Now()
+	for _, window := range UptimeWindows {
+		status.Uptime = append(status.Uptime, check.uptime.uptime(now, window))
+	}
+	return status
+}
+
+// run runs the check, records its result and notifies the check's
+// notifiers if its state changed
+func (m *Monitor) run(check *Check) Result {
+	check.runMu.Lock()
+	defer check.runMu.Unlock()
+
+	ctx := logger.StoreContext(context.Background(), check.Logger)
+	start := time.Now()
+	b, err := checks.RunWithTimeout(ctx, check.Check, check.Timeout)
+	latency := time.Since(start)
+	if err != nil {
+		check.Logger.Error().Err(err).Msg("failed check")
+	}
+	check.Logger.Info().Str("result", string(b)).Dur("latency", latency).Msg("check finished")
+
+	result := Result{
+		Time:    start,
+		Success: err == nil,
+		Latency: latency,
+		Output:  outputExcerpt(b),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.TimedOut = errors.Is(err, checks.ErrTimeout)
+	}
+	m.record(check, result)
+	if m.metrics != nil {
+		m.metrics.ObserveRun(check.Name, check.Type, latency, result.Success, time.Now())
+	}
+
+	transition, changed := check.tracker.Update(result.Success, time.Now())
+	if m.metrics != nil {
+		m.metrics.SetState(check.Name, check.Type, check.tracker.State())
+	}
+	if !changed {
+		m.save(check, result)
+		return result
+	}
+	check.Logger.Info().Str("from", transition.From.String()).Str("to", transition.To.String()).Msg("check changed state")
+	if transition.IsFailure() {
+		check.openIncident(transition.Since, result.Error)
+	} else if transition.IsRecovery() {
+		check.closeIncident(transition.Since)
+	}
+	// the state is persisted before notifying, so a restart right after
+	// notifying does not notify about the same transition again
+	m.save(check, result)
+	// the first successful run is not notified
+	if !transition.IsFailure() && !transition.IsRecovery() {
+		return result
+	}
+	event := newEvent(check, transition, result)
+	check.mu.Lock()
+	checkNotifiers := check.Notifiers
+	check.mu.Unlock()
+	for _, notifier := range checkNotifiers {
+		if err := notifier.Notifier.Notify(event); err != nil {
+			check.Logger.Error().Err(err).Str("notifier", notifier.Name).Msg("failed notifying")
+			if m.metrics != nil {
+				m.metrics.NotificationFailed(check.Name, check.Type, notifier.Name)
+			}
+		}
+	}
+	return result
+}
+
+// save persists the result and the check's state if the monitor has a store
+func (m *Monitor) save(check *Check, result Result) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	if err := persist(store, check, result); err != nil {
+		check.Logger.Error().Err(err).Msg("failed persisting check result")
+	}
+}
+
+// record adds the result to the check's history, dropping the
+// oldest result if the history is full
+func (m *Monitor) record(check *Check, result Result) {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	check.history = append(check.history, result)
+	check.uptime.add(result.Time, result.Success)
+	if !result.Success {
+		check.lastFailure = &result
+	}
+	if len(check.history) > m.historySize {
+		check.history = append([]Result{}, check.history[len(check.history)-m.historySize:]...)
+	}
+}
+
+// newEvent returns the notifiers event of a check's state transition
+func newEvent(check *Check, transition *state.Transition, result Result) notifiers.Event {
+	event := notifiers.Event{
+		CheckName:     check.Name,
+		CheckType:     check.Type,
+		Labels:        check.Labels,
+		Severity:      check.Severity,
+		State:         transition.To,
+		PreviousState: transition.From,
+		Error:         result.Error,
+		TimedOut:      result.TimedOut,
+		Latency:       result.Latency,
+		Output:        result.Output,
+		Timestamp:     transition.At,
+		Since:         transition.Since,
+		Duration:      transition.Duration,
+	}
+	if targeter, ok := check.Check.(checks.Targeter); ok {
+		event.Target = targeter.Target()
+	}
+	if transition.IsFailure() {
+		event.Duration = transition.At.Sub(transition.Since)
+	}
+	return event
+}
+
+// outputExcerpt returns at most the first `maxOutputExcerpt` bytes of a check's output,
+// without splitting a UTF-8 encoded character
+func outputExcerpt(output []byte) string {
+	if len(output) <= maxOutputExcerpt {
+		return string(output)
+	}
+	return truncate.String(string(output), maxOutputExcerpt) + "..."
+}
@@ -0,0 +1,330 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/amitizle/muffin/internal/scheduler"
+	"github.com/amitizle/muffin/pkg/notifiers"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/rs/zerolog"
+)
+
+// fakeCheck is a check that fails when `err` is set
+type fakeCheck struct {
+	err error
+}
+
+func (c *fakeCheck) Initialize(context.Context) error       { return nil }
+func (c *fakeCheck) Configure(map[string]interface{}) error { return nil }
+func (c *fakeCheck) Run(context.Context) ([]byte, error)    { return []byte("output"), c.err }
+func (c *fakeCheck) Target() string                         { return "fake://target" }
+
+// fakeNotifier records the events it's notified about
+type fakeNotifier struct {
+	mu     sync.Mutex
+	events []notifiers.Event
+}
+
+func (n *fakeNotifier) Initialize(context.Context) error       { return nil }
+func (n *fakeNotifier) Configure(map[string]interface{}) error { return nil }
+func (n *fakeNotifier) Notify(event notifiers.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+func newTestMonitor(t *testing.T, check *fakeCheck, notifier *fakeNotifier) *Monitor {
+	m := New(scheduler.New(), nil)
+	err := m.Add(&Check{
+		Name:      "api",
+		Type:      "fake",
+		Cron:      "0 0 * * * *",
+		Check:     check,
+		Notifiers: []*Notifier{{Name: "fake", Notifier: notifier}},
+		Logger:    zerolog.Nop(),
+	})
+	if err != nil {
+		t.Fatalf("failed adding check: %v", err)
+	}
+	return m
+}
+
+func TestAddDuplicateAndInvalidCron(t *testing.T) {
+	m := newTestMonitor(t, &fakeCheck{}, &fakeNotifier{})
+	if err := m.Add(&Check{Name: "api", Cron: "0 0 * * * *", Check: &fakeCheck{}}); err == nil {
+		t.Errorf("expected an error adding a check with a duplicate name")
+	}
+	if err := m.Add(&Check{Name: "other", Cron: "not a cron", Check: &fakeCheck{}}); err == nil {
+		t.Errorf("expected an error adding a check with an invalid cron expression")
+	}
+}
+
+func TestRun(t *testing.T) {
+	check := &fakeCheck{}
+	notifier := &fakeNotifier{}
+	m := newTestMonitor(t, check, notifier)
+
+	status, err := m.Check("api")
+	if err != nil {
+		t.Fatalf("failed getting check status: %v", err)
+	}
+	if status.State != state.Unknown || status.LastResult != nil || status.Target != "fake://target" {
+		t.Fatalf("unexpected status before the first run: %+v", status)
+	}
+
+	check.err = errors.New("connection refused")
+	result, err := m.Run("api")
+	if err != nil {
+		t.Fatalf("failed running check: %v", err)
+	}
+	if result.Success || result.Error != "connection refused" || result.Output != "output" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	check.err = nil
+	m.Run("api")
+
+	status, _ = m.Check("api")
+	if status.State != state.OK || status.LastResult == nil || !status.LastResult.Success {
+		t.Fatalf("unexpected status after recovery: %+v", status)
+	}
+	if status.LastFailure == nil || status.LastFailure.Error != "connection refused" || !status.LastFailure.Time.Before(status.LastResult.Time) {
+		t.Fatalf("expected the last failure to be kept after recovery, got %+v", status.LastFailure)
+	}
+	if len(notifier.events) != 2 || notifier.events[0].State != state.Failing || !notifier.events[1].IsRecovery() {
+		t.Fatalf("expected a failure and a recovery event, got %+v", notifier.events)
+	}
+	if notifier.events[0].Target != "fake://target" {
+		t.Errorf("expected event target to be set, got %q", notifier.events[0].Target)
+	}
+
+	if _, err := m.Run("nope"); !errors.Is(err, ErrCheckNotFound) {
+		t.Errorf("expected ErrCheckNotFound running an unknown check, got %v", err)
+	}
+
+	incidents := m.Incidents(time.Time{})
+	if len(incidents) != 1 {
+		t.Fatalf("expected a single incident, got %+v", incidents)
+	}
+	if incidents[0].Check != "api" || incidents[0].Error != "connection refused" || incidents[0].Ongoing() {
+		t.Errorf("expected a resolved incident of the failed run, got %+v", incidents[0])
+	}
+	if len(m.Incidents(time.Now())) != 0 {
+		t.Errorf("expected no incidents that started after now")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	m := newTestMonitor(t, &fakeCheck{}, &fakeNotifier{})
+	m.historySize = 3
+	for i := 0; i < 5; i++ {
+		m.Run("api")
+	}
+	history, err := m.History("api", 0)
+	if err != nil {
+		t.Fatalf("failed getting history: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected history to be capped at 3 results, got %d", len(history))
+	}
+	if history[0].Time.After(history[2].Time) {
+		t.Errorf("expected history to be ordered oldest first")
+	}
+	history, _ = m.History("api", 2)
+	if len(history) != 2 {
+		t.Fatalf("expected 2 results with a limit, got %d", len(history))
+	}
+	if _, err := m.History("nope", 0); !errors.Is(err, ErrCheckNotFound) {
+		t.Errorf("expected ErrCheckNotFound for an unknown check, got %v", err)
+	}
+}
+
+func TestUptimeCounter(t *testing.T) {
+	now := time.Date(2020, 1, 31, 12, 30, 0, 0, time.UTC)
+	u := &uptimeCounter{}
+	// a failed run 10 days ago, 2 days ago and a successful run every hour of the last day
+	u.add(now.Add(-10*24*time.Hour), false)
+	u.add(now.Add(-2*24*time.Hour), false)
+	for i := 23; i >= 0; i-- {
+		u.add(now.Add(-time.Duration(i)*time.Hour), true)
+	}
+
+	tests := []struct {
+		window        time.Duration
+		expectedName  string
+		expectedRuns  int
+		expectedRatio float64
+	}{
+		{window: 24 * time.Hour, expectedName: "24h", expectedRuns: 24, expectedRatio: 1},
+		{window: 7 * 24 * time.Hour, expectedName: "7d", expectedRuns: 25, expectedRatio: 24.0 / 25},
+		{window: 30 * 24 * time.Hour, expectedName: "30d", expectedRuns: 26, expectedRatio: 24.0 / 26},
+	}
+	for _, test := range tests {
+		uptime := u.uptime(now, test.window)
+		if uptime.WindowName() != test.expectedName {
+			t.Errorf("expected window name %s, got %s", test.expectedName, uptime.WindowName())
+		}
+		if uptime.Runs != test.expectedRuns || uptime.Ratio != test.expectedRatio {
+			t.Errorf("expected %d runs with ratio %v over %s, got %d runs with ratio %v",
+				test.expectedRuns, test.expectedRatio, test.expectedName, uptime.Runs, uptime.Ratio)
+		}
+	}
+
+	// runs older than the longest window are dropped
+	u.add(now.Add(21*24*time.Hour), true)
+	if uptime := u.uptime(now.Add(21*24*time.Hour), 30*24*time.Hour); uptime.Runs != 26 {
+		t.Errorf("expected the run from 31 days ago to be dropped, got %d runs", uptime.Runs)
+	}
+}
+
+func TestOutputExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{name: "short", output: "ok", expected: "ok"},
+		{name: "ascii", output: strings.Repeat("a", maxOutputExcerpt+1), expected: strings.Repeat("a", maxOutputExcerpt) + "..."},
+		{name: "multi-byte", output: strings.Repeat("日本", maxOutputExcerpt), expected: strings.Repeat("日本", 170) + "日..."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			excerpt := outputExcerpt([]byte(test.output))
+			if excerpt != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, excerpt)
+			}
+			if !utf8.ValidString(excerpt) {
+				t.Fatalf("excerpt %q is not valid UTF-8", excerpt)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	m := newTestMonitor(t, &fakeCheck{}, &fakeNotifier{})
+	if err := m.Remove("api"); err != nil {
+		t.Fatalf("failed removing check: %v", err)
+	}
+	if len(m.Checks()) != 0 || m.scheduler.Tasks() != 0 {
+		t.Fatalf("expected the check to be removed and unscheduled")
+	}
+	if err := m.Remove("api"); !errors.Is(err, ErrCheckNotFound) {
+		t.Errorf("expected ErrCheckNotFound removing a removed check, got %v", err)
+	}
+	// the name can be reused
+	if err := m.Add(&Check{Name: "api", Cron: "0 0 * * * *", Check: &fakeCheck{}, Logger: zerolog.Nop()}); err != nil {
+		t.Errorf("failed adding a check with the name of a removed check: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m := newTestMonitor(t, &fakeCheck{err: errors.New("connection refused")}, &fakeNotifier{})
+	m.Run("api")
+
+	notifier := &fakeNotifier{}
+	err := m.Update(&Check{
+		Name:      "api",
+		Type:      "fake",
+		Cron:      "0 30 * * * *",
+		Check:     &fakeCheck{},
+		Notifiers: []*Notifier{{Name: "fake", Notifier: notifier}},
+		Logger:    zerolog.Nop(),
+	})
+	if err != nil {
+		t.Fatalf("failed updating check: %v", err)
+	}
+	if m.scheduler.Tasks() != 1 {
+		t.Fatalf("expected the check to be rescheduled, got %d tasks", m.scheduler.Tasks())
+	}
+	status, _ := m.Check("api")
+	if status.State != state.Failing || status.LastResult == nil {
+		t.Fatalf("expected the state of the check to be kept, got %+v", status)
+	}
+	m.Run("api")
+	if len(notifier.events) != 1 || !notifier.events[0].IsRecovery() {
+		t.Fatalf("expected the updated check to notify a recovery, got %+v", notifier.events)
+	}
+	if history, _ := m.History("api", 0); len(history) != 2 {
+		t.Errorf("expected the history of the check to be kept, got %d results", len(history))
+	}
+
+	if err := m.Update(&Check{Name: "nope", Cron: "0 0 * * * *"}); !errors.Is(err, ErrCheckNotFound) {
+		t.Errorf("expected ErrCheckNotFound updating an unknown check, got %v", err)
+	}
+	if err := m.Update(&Check{Name: "api", Cron: "nope"}); err == nil {
+		t.Errorf("expected an error updating a check with an invalid cron expression")
+	}
+}
+
+// blockingCheck is a check that blocks until it's released
+type blockingCheck struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCheck) Initialize(context.Context) error       { return nil }
+func (c *blockingCheck) Configure(map[string]interface{}) error { return nil }
+func (c *blockingCheck) Run(context.Context) ([]byte, error) {
+	close(c.started)
+	<-c.release
+	return nil, nil
+}
+
+func TestUpdateWhileRunning(t *testing.T) {
+	m := New(scheduler.New(), nil)
+	check := &blockingCheck{started: make(chan struct{}), release: make(chan struct{})}
+	if err := m.Add(&Check{Name: "api", Cron: "0 0 * * * *", Check: check, Logger: zerolog.Nop()}); err != nil {
+		t.Fatalf("failed adding check: %v", err)
+	}
+	go m.Run("api")
+	<-check.started
+
+	updated := make(chan error)
+	go func() {
+		updated <- m.Update(&Check{Name: "api", Cron: "0 30 * * * *", Check: &fakeCheck{}, Logger: zerolog.Nop()})
+	}()
+	// give the update time to start waiting for the run
+	time.Sleep(50 * time.Millisecond)
+	// the monitor is usable while the update waits for the run to finish
+	listed := make(chan struct{})
+	go func() {
+		m.Checks()
+		close(listed)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(time.Second):
+		t.Fatalf("listing checks blocked while a running check was updated")
+	}
+
+	close(check.release)
+	select {
+	case err := <-updated:
+		if err != nil {
+			t.Fatalf("failed updating check: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("updating a running check did not return after the run finished")
+	}
+	if history, _ := m.History("api", 0); len(history) != 1 {
+		t.Errorf("expected the result of the run to be kept, got %d results", len(history))
+	}
+}
+
+func TestSetNotifiers(t *testing.T) {
+	m := newTestMonitor(t, &fakeCheck{err: errors.New("connection refused")}, &fakeNotifier{})
+	notifier := &fakeNotifier{}
+	if err := m.SetNotifiers("api", []*Notifier{{Name: "other", Notifier: notifier}}); err != nil {
+		t.Fatalf("failed setting notifiers: %v", err)
+	}
+	m.Run("api")
+	if len(notifier.events) != 1 {
+		t.Fatalf("expected the new notifier to be notified, got %+v", notifier.events)
+	}
+}
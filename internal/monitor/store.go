@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+// Store persists the results and state of checks, so history and uptime survive
+// restarts and checks resume in the state they were in (i.e without re-alerting).
+// Checks are identified by their names.
+type Store interface {
+	// Record records the result of a check's run and the check's state after it
+	Record(check string, result Result, checkState CheckState) error
+	// Results returns up to `limit` of the most recent results of the check, oldest first
+	Results(check string, limit int) ([]Result, error)
+	// Uptime returns the uptime buckets of the check that started after `since`, oldest first
+	Uptime(check string, since time.Time) ([]UptimeBucket, error)
+	// State returns the last recorded state of the check, or nil if it was never recorded
+	State(check string) (*CheckState, error)
+	// Prune removes data that is older than the store's retention, checks that are
+	// not in `configured` are removed once they have no data left
+	Prune(now time.Time, configured []string) error
+	Close() error
+}
+
+// CheckState is the state of a check that's needed to resume it
+type CheckState struct {
+	Tracker     state.Snapshot
+	Incidents   []Incident
+	LastFailure *Result
+}
+
+// SetStore sets the store checks are persisted to and restored from,
+// it should be called before adding checks
+func (m *Monitor) SetStore(store Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+// restore restores the check's state, history and uptime from the store,
+// it returns false if the store has no state of the check
+func (m *Monitor) restore(check *Check) (bool, error) {
+	checkState, err := m.store.State(check.Name)
+	if err != nil || checkState == nil {
+		return false, err
+	}
+	history, err := m.store.Results(check.Name, m.historySize)
+	if err != nil {
+		return false, err
+	}
+	buckets, err := m.store.Uptime(check.Name, time.Now().Add(-maxUptimeWindow()))
+	if err != nil {
+		return false, err
+	}
+
+	check.tracker.Restore(checkState.Tracker)
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	check.history = history
+	check.uptime.restore(buckets)
+	check.incidents = checkState.Incidents
+	check.lastFailure = checkState.LastFailure
+	return true, nil
+}
+
+// persist records the result and the check's current state in the store
+func persist(store Store, check *Check, result Result) error {
+	check.mu.Lock()
+	checkState := CheckState{
+		Tracker:     check.tracker.Snapshot(),
+		Incidents:   append([]Incident{}, check.incidents...),
+		LastFailure: check.lastFailure,
+	}
+	check.mu.Unlock()
+	return store.Record(check.Name, result, checkState)
+}
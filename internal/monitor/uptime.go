@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// UptimeWindows are the windows the uptime of checks is calculated over
+var UptimeWindows = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
+
+// UptimeResolution is the size of the buckets the runs of a check are counted in,
+// uptime is calculated over whole buckets so it's approximate to this resolution
+const UptimeResolution = time.Hour
+
+// Uptime is the uptime of a check over a window
+type Uptime struct {
+	Window time.Duration
+	// Ratio is the ratio of successful runs in the window, between 0 and 1
+	Ratio float64
+	Runs  int
+}
+
+// WindowName returns a short name of the uptime's window, i.e "24h" or "7d"
+func (u Uptime) WindowName() string {
+	if u.Window%(24*time.Hour) == 0 && u.Window > 24*time.Hour {
+		return fmt.Sprintf("%dd", u.Window/(24*time.Hour))
+	}
+	return fmt.Sprintf("%dh", u.Window/time.Hour)
+}
+
+// UptimeBucket counts the runs of a check that started within `UptimeResolution` of `Start`
+type UptimeBucket struct {
+	Start     time.Time
+	Runs      int
+	Successes int
+}
+
+// uptimeCounter counts the runs of a check in buckets, it keeps
+// enough buckets for the longest window in `UptimeWindows`
+type uptimeCounter struct {
+	buckets []UptimeBucket
+}
+
+// restore replaces the counted runs with the given buckets, oldest first
+func (u *uptimeCounter) restore(buckets []UptimeBucket) {
+	u.buckets = append([]UptimeBucket{}, buckets...)
+}
+
+// add counts a single run
+func (u *uptimeCounter) add(at time.Time, success bool) {
+	start := at.Truncate(UptimeResolution)
+	if n := len(u.buckets); n == 0 || u.buckets[n-1].Start.Before(start) {
+		u.buckets = append(u.buckets, UptimeBucket{Start: start})
+	}
+	// runs are added in order, late runs are counted in the last bucket
+	last := &u.buckets[len(u.buckets)-1]
+	last.Runs++
+	if success {
+		last.Successes++
+	}
+
+	oldest := start.Add(-maxUptimeWindow())
+	drop := 0
+	for drop < len(u.buckets) && !u.buckets[drop].Start.After(oldest) {
+		drop++
+	}
+	if drop > 0 {
+		u.buckets = append([]UptimeBucket{}, u.buckets[drop:]...)
+	}
+}
+
+// uptime returns the uptime over the given window, ending at `now`
+func (u *uptimeCounter) uptime(now time.Time, window time.Duration) Uptime {
+	result := Uptime{Window: window}
+	since := now.Truncate(UptimeResolution).Add(-window)
+	successes := 0
+	for _, bucket := range u.buckets {
+		if bucket.Start.After(since) {
+			result.Runs += bucket.Runs
+			successes += bucket.Successes
+		}
+	}
+	if result.Runs > 0 {
+		result.Ratio = float64(successes) / float64(result.Runs)
+	}
+	return result
+}
+
+func maxUptimeWindow() time.Duration {
+	max := time.Duration(0)
+	for _, window := range UptimeWindows {
+		if window > max {
+			max = window
+		}
+	}
+	return max
+}
+
+// UptimePercentages returns the uptime percentages by window name (see `WindowName`),
+// the percentage of windows without any runs is nil
+func UptimePercentages(uptimes []Uptime) map[string]*float64 {
+	percentages := map[string]*float64{}
+	for _, uptime := range uptimes {
+		percentages[uptime.WindowName()] = nil
+		if uptime.Runs > 0 {
+			percentage := uptime.Ratio * 100
+			percentages[uptime.WindowName()] = &percentage
+		}
+	}
+	return percentages
+}
@@ -0,0 +1,24 @@
+package offline
+
+import "context"
+
+// ctxKeyOffline is a custom type that will be used as the key
+// of the offline mode in context.Context
+type ctxKeyOffline int
+
+// OfflineCtxKey is the key that marks a context as offline
+const OfflineCtxKey ctxKeyOffline = 0
+
+// StoreContext marks the context as offline and returns the new context.
+// Checks and notifiers that are initialized with an offline context are only
+// configured to validate their configuration (i.e by `muffin validate`), so
+// they should not reach the network (i.e to verify tokens) in `Configure`.
+func StoreContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, OfflineCtxKey, true)
+}
+
+// IsOffline returns true if the context was marked as offline
+func IsOffline(ctx context.Context) bool {
+	offline, _ := ctx.Value(OfflineCtxKey).(bool)
+	return offline
+}
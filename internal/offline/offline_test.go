@@ -0,0 +1,15 @@
+package offline
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsOffline(t *testing.T) {
+	if IsOffline(context.Background()) {
+		t.Error("expected a context that was not marked to not be offline")
+	}
+	if !IsOffline(StoreContext(context.Background())) {
+		t.Error("expected a context that was marked to be offline")
+	}
+}
@@ -1,12 +1,21 @@
 package scheduler
 
-import "github.com/robfig/cron/v3"
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
 
 // Scheduler is a struct that defines an instance of a scheduler.
 // It holds the tasks (`[]*tasks`).
 type Scheduler struct {
 	tasks []*task
 	c     *cron.Cron
+
+	// running is the number of tasks that are currently running
+	running int64
 }
 
 type task struct {
@@ -17,6 +26,9 @@ type task struct {
 // when adding tasks to the scheduler
 type TaskFunc func()
 
+// parser parses cron formatted strings with seconds, it's the parser of `cron.WithSeconds`
+var parser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 // nilLogger is a logger that will be used with `cron`.
 // We don't need extra logging so better explicitly not logging anything ¯\_(ツ)_/¯
 type nilLogger struct{}
@@ -38,25 +50,76 @@ func (l *nilLogger) Error(err error, msg string, keysAndValues ...interface{}) {
 func New() *Scheduler {
 	return &Scheduler{
 		tasks: []*task{},
-		c:     cron.New(cron.WithSeconds(), cron.WithLogger(&nilLogger{})),
+		c:     cron.New(cron.WithParser(parser), cron.WithLogger(&nilLogger{})),
 	}
 }
 
-// Start starts the scheduler. This should be called after adding tasks
-// using NewTask, as it's currently impossible to add tasks to an already started
-// scheduler.
+// Start starts the scheduler. Tasks can be added (`NewTask`, `AddTask`)
+// and removed (`RemoveTask`) before and after it's started.
 func (s *Scheduler) Start() error {
 	s.c.Start()
 	return nil
 }
 
+// TaskID identifies a task that was added to the scheduler
+type TaskID cron.EntryID
+
 // NewTask adds a new scheduler task. It receives a cron formatted string
 // and a function of the type `TaskFunc` that's defined in this package.
 func (s *Scheduler) NewTask(cronfmt string, fn func()) error {
-	_, err := s.c.AddFunc(cronfmt, fn)
+	_, err := s.AddTask(cronfmt, fn)
+	return err
+}
+
+// AddTask is like `NewTask` but it also returns the ID of the new task
+func (s *Scheduler) AddTask(cronfmt string, fn func()) (TaskID, error) {
+	id, err := s.c.AddFunc(cronfmt, func() {
+		atomic.AddInt64(&s.running, 1)
+		defer atomic.AddInt64(&s.running, -1)
+		fn()
+	})
+	return TaskID(id), err
+}
+
+// RemoveTask removes the given task, it does not stop the task if it's running
+func (s *Scheduler) RemoveTask(id TaskID) {
+	s.c.Remove(cron.EntryID(id))
+}
+
+// Validate returns an error if the given cron formatted string is invalid
+func Validate(cronfmt string) error {
+	_, err := parser.Parse(cronfmt)
 	return err
 }
 
+// Next returns the next time the given task is scheduled to run,
+// it's zero if the scheduler was not started or the task does not exist
+func (s *Scheduler) Next(id TaskID) time.Time {
+	return s.c.Entry(cron.EntryID(id)).Next
+}
+
+// Tasks returns the number of scheduled tasks
+func (s *Scheduler) Tasks() int {
+	return len(s.c.Entries())
+}
+
+// Running returns the number of tasks that are currently running
+func (s *Scheduler) Running() int {
+	return int(atomic.LoadInt64(&s.running))
+}
+
 func (s *Scheduler) Stop() {
 	s.c.Stop()
 }
+
+// Shutdown stops the scheduler and waits for the running tasks to finish or for the
+// context to be done, in which case the context's error is returned
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	stopped := s.c.Stop()
+	select {
+	case <-stopped.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,7 +1,10 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestSchedulerRunningFuncs(t *testing.T) {
@@ -23,3 +26,115 @@ func TestSchedulerRunningFuncs(t *testing.T) {
 	}
 	s.Stop()
 }
+
+func TestSchedulerTasksAndRunning(t *testing.T) {
+	s := New()
+	started := make(chan bool)
+	release := make(chan bool)
+	if err := s.NewTask("* * * * * *", func() {
+		started <- true
+		<-release
+	}); err != nil {
+		t.Fatalf("could not add new task to scheduler: %v", err)
+	}
+	if s.Tasks() != 1 {
+		t.Fatalf("expected 1 task, got %d", s.Tasks())
+	}
+	s.Start()
+	<-started
+	if s.Running() != 1 {
+		t.Fatalf("expected 1 running task, got %d", s.Running())
+	}
+	s.Stop()
+	close(release)
+}
+
+func TestSchedulerNext(t *testing.T) {
+	s := New()
+	id, err := s.AddTask("0 0 * * * *", func() {})
+	if err != nil {
+		t.Fatalf("could not add new task to scheduler: %v", err)
+	}
+	if next := s.Next(id); !next.IsZero() {
+		t.Fatalf("expected no next run before the scheduler starts, got %s", next)
+	}
+	s.Start()
+	defer s.Stop()
+	next := s.Next(id)
+	if next.IsZero() || next.Minute() != 0 || next.Second() != 0 {
+		t.Fatalf("expected next run at the beginning of an hour, got %s", next)
+	}
+	if next := s.Next(id + 1); !next.IsZero() {
+		t.Fatalf("expected no next run for an unknown task, got %s", next)
+	}
+}
+
+func TestSchedulerRemoveTask(t *testing.T) {
+	s := New()
+	id, err := s.AddTask("0 0 * * * *", func() {})
+	if err != nil {
+		t.Fatalf("could not add new task to scheduler: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+	// tasks can be added to a started scheduler
+	if _, err := s.AddTask("0 0 * * * *", func() {}); err != nil {
+		t.Fatalf("could not add new task to a started scheduler: %v", err)
+	}
+	s.RemoveTask(id)
+	if s.Tasks() != 1 {
+		t.Fatalf("expected 1 task after removing a task, got %d", s.Tasks())
+	}
+	if next := s.Next(id); !next.IsZero() {
+		t.Fatalf("expected no next run for a removed task, got %s", next)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for cronfmt, valid := range map[string]bool{
+		"* * * * * *":   true,
+		"0 */5 * * * *": true,
+		"@every 10s":    true,
+		"* * * * *":     false,
+		"not a cron":    false,
+	} {
+		if err := Validate(cronfmt); (err == nil) != valid {
+			t.Errorf("expected %q to be valid: %v, got error: %v", cronfmt, valid, err)
+		}
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	s := New()
+	started := make(chan bool, 1)
+	release := make(chan bool)
+	finished := false
+	s.NewTask("* * * * * *", func() {
+		select {
+		case started <- true:
+		default:
+			return
+		}
+		<-release
+		finished = true
+	})
+	s.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected shutdown to time out waiting for the running task, got %v", err)
+	}
+
+	close(release)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected shutdown to wait for the running task, got %v", err)
+	}
+	if !finished {
+		t.Fatalf("expected the running task to finish before shutdown returns")
+	}
+	if s.Running() != 0 {
+		t.Fatalf("expected no running tasks after shutdown, got %d", s.Running())
+	}
+}
@@ -0,0 +1,85 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	// ErrAmbiguousSource is returned when a secret is configured with more
+	// than one source (value, env and file)
+	ErrAmbiguousSource = errors.New("secret must have exactly one of value, env or file")
+)
+
+// Secret is a configuration value that should not necessarily be written in the
+// configuration file. It can be given inline (`value`), read from an environment
+// variable (`env`) or read from a file (`file`), i.e
+//
+//	bearer_token:
+//	  env: API_TOKEN
+//
+// A plain string is decoded as an inline value when using `DecodeHook`.
+type Secret struct {
+	Value string `mapstructure:"value"`
+	Env   string `mapstructure:"env"`
+	File  string `mapstructure:"file"`
+}
+
+// IsSet returns true if any of the secret's sources is configured
+func (s *Secret) IsSet() bool {
+	return s != nil && (s.Value != "" || s.Env != "" || s.File != "")
+}
+
+// Resolve returns the secret's value from its configured source.
+// File contents are returned without trailing newlines.
+func (s *Secret) Resolve() (string, error) {
+	if !s.IsSet() {
+		return "", nil
+	}
+	sources := 0
+	for _, source := range []string{s.Value, s.Env, s.File} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", ErrAmbiguousSource
+	}
+
+	switch {
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return v, nil
+	case s.File != "":
+		b, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return s.Value, nil
+}
+
+// DecodeHook returns a `mapstructure` decode hook that decodes plain strings
+// into a `Secret` with an inline value
+func DecodeHook() mapstructure.DecodeHookFuncType {
+	secretType := reflect.TypeOf(Secret{})
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from == nil || from.Kind() != reflect.String {
+			return data, nil
+		}
+		if to != secretType && !(to.Kind() == reflect.Ptr && to.Elem() == secretType) {
+			return data, nil
+		}
+		return map[string]interface{}{"value": data}, nil
+	}
+}
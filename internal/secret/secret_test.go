@@ -0,0 +1,84 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "muffin-secret")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("could not write secret file: %v", err)
+	}
+	os.Setenv("MUFFIN_TEST_SECRET", "from-env")
+	defer os.Unsetenv("MUFFIN_TEST_SECRET")
+
+	tests := []struct {
+		name       string
+		secret     *Secret
+		expected   string
+		shouldFail bool
+	}{
+		{name: "nil secret", secret: nil, expected: ""},
+		{name: "inline value", secret: &Secret{Value: "inline"}, expected: "inline"},
+		{name: "environment variable", secret: &Secret{Env: "MUFFIN_TEST_SECRET"}, expected: "from-env"},
+		{name: "missing environment variable", secret: &Secret{Env: "MUFFIN_TEST_SECRET_MISSING"}, shouldFail: true},
+		{name: "file", secret: &Secret{File: secretFile}, expected: "from-file"},
+		{name: "missing file", secret: &Secret{File: filepath.Join(dir, "missing")}, shouldFail: true},
+		{name: "more than one source", secret: &Secret{Value: "inline", Env: "MUFFIN_TEST_SECRET"}, shouldFail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := test.secret.Resolve()
+			if test.shouldFail {
+				if err == nil {
+					t.Fatalf("expected resolving the secret to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error when resolving secret: %v", err)
+			}
+			if v != test.expected {
+				t.Fatalf("expected secret to be %q, got %q", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestDecodeHook(t *testing.T) {
+	out := struct {
+		Plain  *Secret `mapstructure:"plain"`
+		Nested Secret  `mapstructure:"nested"`
+	}{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: DecodeHook(),
+		Result:     &out,
+	})
+	if err != nil {
+		t.Fatalf("could not create decoder: %v", err)
+	}
+	err = decoder.Decode(map[string]interface{}{
+		"plain":  "inline",
+		"nested": map[string]interface{}{"env": "SOME_VAR"},
+	})
+	if err != nil {
+		t.Fatalf("could not decode secrets: %v", err)
+	}
+	if out.Plain == nil || out.Plain.Value != "inline" {
+		t.Errorf("expected plain string to be decoded as an inline secret, got %+v", out.Plain)
+	}
+	if out.Nested.Env != "SOME_VAR" {
+		t.Errorf("expected nested secret to be decoded with env, got %+v", out.Nested)
+	}
+}
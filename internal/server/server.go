@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Server is the daemon's HTTP server, handlers are added to it
+// with `Handle` before calling `Start`
+type Server struct {
+	mux    *http.ServeMux
+	srv    *http.Server
+	ln     net.Listener
+	logger zerolog.Logger
+}
+
+// New returns a new server that will listen on the given address
+func New(address string, logger zerolog.Logger) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		mux: mux,
+		srv: &http.Server{
+			Addr:              address,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Handle registers the handler for the given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start starts listening and serving in the background, it returns
+// an error if the server could not listen on its address
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.logger.Info().Str("address", ln.Addr().String()).Msg("HTTP server listening")
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.logger.Error().Err(err).Msg("HTTP server failed")
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on,
+// it's only available after `Start`
+func (s *Server) Addr() string {
+	if s.ln == nil {
+		return ""
+	}
+	return s.ln.Addr().String()
+}
+
+// Shutdown gracefully shuts down the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,37 @@
+package statuspage
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// funcs are the functions available to status page templates
+var funcs = template.FuncMap{
+	// uptime formats an uptime percentage, i.e "99.95%" or "n/a" if there were no runs
+	"uptime": func(percentage *float64) string {
+		if percentage == nil {
+			return "n/a"
+		}
+		if *percentage == 100 {
+			return "100%"
+		}
+		return fmt.Sprintf("%.2f%%", *percentage)
+	},
+	// duration formats a number of seconds as a duration, i.e "1h2m0s"
+	"duration": func(seconds float64) string {
+		return (time.Duration(seconds) * time.Second).Round(time.Second).String()
+	},
+	// datetime formats a `time.Time` or a `*time.Time` in UTC
+	"datetime": func(t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			return v.UTC().Format("2006-01-02 15:04 MST")
+		case *time.Time:
+			if v != nil {
+				return v.UTC().Format("2006-01-02 15:04 MST")
+			}
+		}
+		return ""
+	},
+}
@@ -0,0 +1,24 @@
+package statuspage
+
+import (
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+)
+
+type monitorSource struct {
+	monitor *monitor.Monitor
+}
+
+// MonitorSource returns a source of the checks of the given monitor
+func MonitorSource(m *monitor.Monitor) Source {
+	return &monitorSource{monitor: m}
+}
+
+func (s *monitorSource) Checks() ([]monitor.Status, error) {
+	return s.monitor.Checks(), nil
+}
+
+func (s *monitorSource) Incidents(since time.Time) ([]monitor.Incident, error) {
+	return s.monitor.Incidents(since), nil
+}
@@ -0,0 +1,317 @@
+package statuspage
+
+import (
+	"bytes"
+	_ "embed" // the default template is embedded in the binary
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+// Names of the files that are written to the output directory
+const (
+	HTMLFile = "index.html"
+	JSONFile = "status.json"
+)
+
+//go:embed templates/default.html
+var defaultTemplate string
+
+// Config is the configuration of the status page
+type Config struct {
+	Title string `yaml:"title"`
+	// Cron is the schedule of rendering the status page by `muffin start`,
+	// the status page is not rendered periodically if it's empty
+	Cron string `yaml:"cron"`
+	// Output is the directory the status page is written to
+	Output string `yaml:"output"`
+	// Template is the path of a custom `html/template` file, it's executed with a `*Page`
+	Template string `yaml:"template"`
+	// IncidentDays is the number of days of incident history on the status page
+	IncidentDays int          `yaml:"incident_days" mapstructure:"incident_days"`
+	Components   []*Component `yaml:"components"`
+}
+
+// Component is a group of checks that's shown as a single item on the status page,
+// i.e "API" for all of the checks of the API's endpoints
+type Component struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Checks      []string `yaml:"checks"`
+}
+
+// Source is where the status page gets the checks' status and history from,
+// i.e a `*monitor.Monitor` (see `MonitorSource`) or a running daemon's API
+type Source interface {
+	Checks() ([]monitor.Status, error)
+	Incidents(since time.Time) ([]monitor.Incident, error)
+}
+
+// Status is the status of a component or of the entire page
+type Status string
+
+// Statuses of components
+const (
+	Operational Status = "operational"
+	Degraded    Status = "degraded"
+	Outage      Status = "outage"
+	Unknown     Status = "unknown"
+)
+
+// Page is the data the status page is rendered from, it's also written as is to `JSONFile`.
+// It's meant to be public so it does not include check errors, targets or labels.
+type Page struct {
+	Title       string             `json:"title"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Status      Status             `json:"status"`
+	Components  []*ComponentStatus `json:"components"`
+	Incidents   []*Incident        `json:"incidents"`
+}
+
+// ComponentStatus is the status of a component
+type ComponentStatus struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Status      Status              `json:"status"`
+	Uptime      map[string]*float64 `json:"uptime"`
+	Checks      []*CheckStatus      `json:"checks"`
+}
+
+// CheckStatus is the status of a single check of a component
+type CheckStatus struct {
+	Name   string              `json:"name"`
+	State  state.State         `json:"state"`
+	Since  *time.Time          `json:"since,omitempty"`
+	Uptime map[string]*float64 `json:"uptime"`
+}
+
+// Incident is an incident of one of the checks, `End` is nil while it's ongoing
+type Incident struct {
+	Check      string     `json:"check"`
+	Components []string   `json:"components"`
+	Start      time.Time  `json:"start"`
+	End        *time.Time `json:"end,omitempty"`
+	Ongoing    bool       `json:"ongoing"`
+	Duration   float64    `json:"duration_seconds"`
+}
+
+// Renderer renders status pages
+type Renderer struct {
+	config   *Config
+	template *template.Template
+	now      func() time.Time
+}
+
+// New returns a new renderer, it returns an error if the custom template
+// could not be read or parsed
+func New(config *Config) (*Renderer, error) {
+	text := defaultTemplate
+	if config.Template != "" {
+		b, err := ioutil.ReadFile(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading status page template: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := template.New("status-page").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing status page template: %w", err)
+	}
+	return &Renderer{config: config, template: tmpl, now: time.Now}, nil
+}
+
+// Build builds the status page from the given source, it returns an error if
+// the source failed or a component references a check that does not exist
+func (r *Renderer) Build(source Source, now time.Time) (*Page, error) {
+	statuses, err := source.Checks()
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := source.Incidents(now.AddDate(0, 0, -r.config.IncidentDays))
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]monitor.Status{}
+	for _, status := range statuses {
+		byName[status.Name] = status
+	}
+	components := r.config.Components
+	if len(components) == 0 {
+		// without components every check is a component of its own
+		components = []*Component{}
+		for _, status := range statuses {
+			components = append(components, &Component{Name: status.Name, Checks: []string{status.Name}})
+		}
+	}
+
+	page := &Page{
+		Title:       r.config.Title,
+		GeneratedAt: now,
+		Components:  []*ComponentStatus{},
+		Incidents:   []*Incident{},
+	}
+	checkComponents := map[string][]string{}
+	for _, component := range components {
+		componentStatus := &ComponentStatus{
+			Name:        component.Name,
+			Description: component.Description,
+			Checks:      []*CheckStatus{},
+		}
+		checkStatuses := []monitor.Status{}
+		for _, name := range component.Checks {
+			status, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("status page component %q references check %q which does not exist", component.Name, name)
+			}
+			checkStatuses = append(checkStatuses, status)
+			checkComponents[name] = append(checkComponents[name], component.Name)
+			checkStatus := &CheckStatus{Name: status.Name, State: status.State, Uptime: monitor.UptimePercentages(status.Uptime)}
+			if !status.Since.IsZero() {
+				since := status.Since
+				checkStatus.Since = &since
+			}
+			componentStatus.Checks = append(componentStatus.Checks, checkStatus)
+		}
+		componentStatus.Status = componentState(checkStatuses)
+		componentStatus.Uptime = componentUptime(checkStatuses)
+		page.Components = append(page.Components, componentStatus)
+	}
+	page.Status = pageStatus(page.Components)
+
+	for _, incident := range incidents {
+		if _, ok := checkComponents[incident.Check]; !ok {
+			// incidents of checks that are not on the page are not shown
+			continue
+		}
+		i := &Incident{
+			Check:      incident.Check,
+			Components: checkComponents[incident.Check],
+			Start:      incident.Start,
+			Ongoing:    incident.Ongoing(),
+			Duration:   incident.Duration(now).Seconds(),
+		}
+		if !incident.Ongoing() {
+			end := incident.End
+			i.End = &end
+		}
+		page.Incidents = append(page.Incidents, i)
+	}
+	return page, nil
+}
+
+// Render builds the status page from the given source and writes it to the
+// configured output directory as `HTMLFile` and `JSONFile`
+func (r *Renderer) Render(source Source) error {
+	page, err := r.Build(source, r.now())
+	if err != nil {
+		return err
+	}
+	html := &bytes.Buffer{}
+	if err := r.template.Execute(html, page); err != nil {
+		return fmt.Errorf("failed rendering status page template: %w", err)
+	}
+	b, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(r.config.Output, 0755); err != nil {
+		return fmt.Errorf("failed creating status page output directory: %w", err)
+	}
+	if err := writeFile(filepath.Join(r.config.Output, HTMLFile), html.Bytes()); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(r.config.Output, JSONFile), b)
+}
+
+// writeFile writes the file atomically, so whatever publishes the
+// output directory never sees a partially written file
+func writeFile(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed writing status page: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed writing status page: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed writing status page: %w", err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("failed writing status page: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed writing status page: %w", err)
+	}
+	return nil
+}
+
+// componentState returns the status of a component by the states of its checks
+func componentState(statuses []monitor.Status) Status {
+	ok, failing := 0, 0
+	for _, status := range statuses {
+		switch status.State {
+		case state.OK:
+			ok++
+		case state.Failing:
+			failing++
+		}
+	}
+	switch {
+	case failing > 0 && ok == 0:
+		return Outage
+	case failing > 0:
+		return Degraded
+	case ok > 0:
+		return Operational
+	}
+	return Unknown
+}
+
+// pageStatus returns the status of the entire page by the statuses of its components
+func pageStatus(components []*ComponentStatus) Status {
+	status := Unknown
+	for _, component := range components {
+		switch component.Status {
+		case Outage, Degraded:
+			return Degraded
+		case Operational:
+			status = Operational
+		}
+	}
+	return status
+}
+
+// componentUptime returns the average uptime percentages of
+// the checks of a component that had runs in each window
+func componentUptime(statuses []monitor.Status) map[string]*float64 {
+	percentages := map[string]*float64{}
+	for _, window := range monitor.UptimeWindows {
+		name := monitor.Uptime{Window: window}.WindowName()
+		total, count := 0.0, 0
+		for _, status := range statuses {
+			for _, uptime := range status.Uptime {
+				if uptime.Window == window && uptime.Runs > 0 {
+					total += uptime.Ratio * 100
+					count++
+				}
+			}
+		}
+		percentages[name] = nil
+		if count > 0 {
+			average := total / float64(count)
+			percentages[name] = &average
+		}
+	}
+	return percentages
+}
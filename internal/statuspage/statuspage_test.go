@@ -0,0 +1,202 @@
+package statuspage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/monitor"
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+var now = time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC)
+
+type fakeSource struct {
+	statuses  []monitor.Status
+	incidents []monitor.Incident
+}
+
+func (s *fakeSource) Checks() ([]monitor.Status, error) { return s.statuses, nil }
+func (s *fakeSource) Incidents(since time.Time) ([]monitor.Incident, error) {
+	incidents := []monitor.Incident{}
+	for _, incident := range s.incidents {
+		if incident.Start.After(since) {
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents, nil
+}
+
+func uptimes(ratio float64, runs int) []monitor.Uptime {
+	result := []monitor.Uptime{}
+	for _, window := range monitor.UptimeWindows {
+		result = append(result, monitor.Uptime{Window: window, Ratio: ratio, Runs: runs})
+	}
+	return result
+}
+
+func testSource() *fakeSource {
+	return &fakeSource{
+		statuses: []monitor.Status{
+			{Name: "api health", State: state.OK, Uptime: uptimes(1, 10)},
+			{Name: "api login", State: state.Failing, Uptime: uptimes(0.5, 10), Target: "http://internal.example.com"},
+			{Name: "website", State: state.OK, Uptime: uptimes(0, 0)},
+			{Name: "database", State: state.Unknown, Uptime: uptimes(0, 0)},
+		},
+		incidents: []monitor.Incident{
+			{Check: "api login", Start: now.Add(-time.Hour), Error: "connection refused to 10.0.0.1"},
+			{Check: "website", Start: now.Add(-48 * time.Hour), End: now.Add(-47 * time.Hour)},
+			{Check: "website", Start: now.AddDate(0, 0, -30), End: now.AddDate(0, 0, -30).Add(time.Hour)},
+			{Check: "database", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)},
+		},
+	}
+}
+
+func testConfig(output string) *Config {
+	return &Config{
+		Title:        "Example status",
+		Output:       output,
+		IncidentDays: 14,
+		Components: []*Component{
+			{Name: "API", Description: "public API", Checks: []string{"api health", "api login"}},
+			{Name: "Website", Checks: []string{"website"}},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	r, err := New(testConfig(""))
+	if err != nil {
+		t.Fatalf("failed creating renderer: %v", err)
+	}
+	page, err := r.Build(testSource(), now)
+	if err != nil {
+		t.Fatalf("failed building status page: %v", err)
+	}
+
+	if page.Status != Degraded {
+		t.Errorf("expected page to be degraded, got %s", page.Status)
+	}
+	if len(page.Components) != 2 {
+		t.Fatalf("expected 2 components, got %d", len(page.Components))
+	}
+	api := page.Components[0]
+	if api.Status != Degraded || len(api.Checks) != 2 || *api.Uptime["24h"] != 75 {
+		t.Errorf("unexpected API component: %+v", api)
+	}
+	website := page.Components[1]
+	if website.Status != Operational || website.Uptime["24h"] != nil {
+		t.Errorf("unexpected website component: %+v", website)
+	}
+
+	// the database is not on the page and the old website incident is out of the incident history
+	if len(page.Incidents) != 2 {
+		t.Fatalf("expected 2 incidents, got %+v", page.Incidents)
+	}
+	if !page.Incidents[0].Ongoing || page.Incidents[0].Components[0] != "API" || page.Incidents[0].Duration != 3600 {
+		t.Errorf("unexpected ongoing incident: %+v", page.Incidents[0])
+	}
+	if page.Incidents[1].Ongoing || page.Incidents[1].End == nil {
+		t.Errorf("unexpected resolved incident: %+v", page.Incidents[1])
+	}
+}
+
+func TestBuildWithoutComponents(t *testing.T) {
+	r, _ := New(&Config{})
+	page, err := r.Build(testSource(), now)
+	if err != nil {
+		t.Fatalf("failed building status page: %v", err)
+	}
+	if len(page.Components) != 4 || page.Components[3].Name != "database" || page.Components[3].Status != Unknown {
+		t.Errorf("expected a component for every check, got %+v", page.Components)
+	}
+}
+
+func TestBuildUnknownCheck(t *testing.T) {
+	config := testConfig("")
+	config.Components[1].Checks = []string{"nope"}
+	r, _ := New(config)
+	if _, err := r.Build(testSource(), now); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("expected an error about the unknown check, got %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "muffin-status-page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := New(testConfig(filepath.Join(dir, "public")))
+	if err != nil {
+		t.Fatalf("failed creating renderer: %v", err)
+	}
+	r.now = func() time.Time { return now }
+	if err := r.Render(testSource()); err != nil {
+		t.Fatalf("failed rendering status page: %v", err)
+	}
+
+	html, err := ioutil.ReadFile(filepath.Join(dir, "public", HTMLFile))
+	if err != nil {
+		t.Fatalf("failed reading %s: %v", HTMLFile, err)
+	}
+	for _, expected := range []string{"Example status", "public API", "Some systems are experiencing issues", "ongoing for 1h0m0s", "uptime 24h 75.00%"} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("expected status page to contain %q", expected)
+		}
+	}
+	for _, private := range []string{"internal.example.com", "10.0.0.1"} {
+		if strings.Contains(string(html), private) {
+			t.Errorf("expected status page not to contain %q", private)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "public", JSONFile))
+	if err != nil {
+		t.Fatalf("failed reading %s: %v", JSONFile, err)
+	}
+	page := &Page{}
+	if err := json.Unmarshal(b, page); err != nil {
+		t.Fatalf("failed decoding %s: %v", JSONFile, err)
+	}
+	if page.Title != "Example status" || len(page.Components) != 2 || page.Components[0].Checks[1].State != state.Failing {
+		t.Errorf("unexpected JSON status page: %s", b)
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "muffin-status-page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	template := filepath.Join(dir, "template.html")
+	ioutil.WriteFile(template, []byte(`{{ .Title }}:{{ range .Components }} {{ .Name }}={{ .Status }}{{ end }}`), 0644)
+	config := testConfig(dir)
+	config.Template = template
+	r, err := New(config)
+	if err != nil {
+		t.Fatalf("failed creating renderer: %v", err)
+	}
+	r.now = func() time.Time { return now }
+	if err := r.Render(testSource()); err != nil {
+		t.Fatalf("failed rendering status page: %v", err)
+	}
+	html, _ := ioutil.ReadFile(filepath.Join(dir, HTMLFile))
+	if string(html) != "Example status: API=degraded Website=operational" {
+		t.Errorf("unexpected custom status page: %s", html)
+	}
+
+	ioutil.WriteFile(template, []byte(`{{ .Title `), 0644)
+	if _, err := New(config); err == nil {
+		t.Errorf("expected an error parsing an invalid template")
+	}
+	config.Template = filepath.Join(dir, "nope.html")
+	if _, err := New(config); err == nil {
+		t.Errorf("expected an error reading a missing template")
+	}
+}
@@ -0,0 +1,16 @@
+package truncate
+
+import "unicode/utf8"
+
+// String truncates a string to at most n bytes, without splitting a UTF-8 encoded character
+func String(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := n; i > 0 && i > n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			return s[:i]
+		}
+	}
+	return s[:n]
+}
@@ -0,0 +1,34 @@
+package truncate
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		n        int
+		expected string
+	}{
+		{name: "short", input: "disk full", n: 20, expected: "disk full"},
+		{name: "ascii", input: "disk full", n: 4, expected: "disk"},
+		{name: "cut inside a character", input: "héllo", n: 2, expected: "h"},
+		{name: "cut after a character", input: "héllo", n: 3, expected: "hé"},
+		{name: "cut inside an emoji", input: "ok 🔥🔥", n: 6, expected: "ok "},
+		{name: "long multi-byte input", input: strings.Repeat("日本", 1000), n: 1024, expected: strings.Repeat("日本", 170) + "日"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			truncated := String(test.input, test.n)
+			if truncated != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, truncated)
+			}
+			if !utf8.ValidString(truncated) {
+				t.Fatalf("truncated string %q is not valid UTF-8", truncated)
+			}
+		})
+	}
+}
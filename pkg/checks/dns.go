@@ -0,0 +1,222 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// defaultResolvConf is the file used to find a resolver in case
+	// one is not configured
+	defaultResolvConf = "/etc/resolv.conf"
+	defaultDNSPort    = "53"
+)
+
+// dnsRecordTypes are the record types supported by the DNS check
+var dnsRecordTypes = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"MX":    dns.TypeMX,
+	"TXT":   dns.TypeTXT,
+	"SRV":   dns.TypeSRV,
+	"NS":    dns.TypeNS,
+}
+
+// DNSCheck is a struct that defines the DNS check.
+// It queries a name for a given record type and verifies the answers.
+type DNSCheck struct {
+	client *dns.Client
+	config *DNSCheckConfig
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+// DNSCheckConfig is a struct that holds the configuration required for the DNS check.
+// Answers are compared to `Expected` in their textual form:
+//
+//	A, AAAA: the IP address ("192.0.2.1")
+//	CNAME, NS: the target name ("host.example.com")
+//	MX: preference and host ("10 mx.example.com")
+//	SRV: priority, weight, port and target ("10 5 5060 sip.example.com")
+//	TXT: the record's strings concatenated
+//
+// Every expected answer has to be present in the response.
+type DNSCheckConfig struct {
+	Name       string   `mapstructure:"name"`
+	RecordType string   `mapstructure:"record_type"`
+	Resolver   string   `mapstructure:"resolver"`
+	Protocol   string   `mapstructure:"protocol"`
+	Expected   []string `mapstructure:"expected"`
+	MinAnswers int      `mapstructure:"min_answers"`
+
+	// private fields
+	qtype uint16
+}
+
+// Initialize initializes the DNS check
+func (check *DNSCheck) Initialize(ctx context.Context) error {
+	check.ctx = ctx
+	lg, err := logger.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	check.logger = lg
+	return nil
+}
+
+// Configure decodes map[string]interface{} to a DNSCheckConfig struct instance.
+// If no resolver is given, the first nameserver from /etc/resolv.conf is used.
+func (check *DNSCheck) Configure(config map[string]interface{}) error {
+	dnsConfig := &DNSCheckConfig{}
+	if err := mapdecode.Decode(config, dnsConfig); err != nil {
+		return err
+	}
+	if dnsConfig.Name == "" {
+		return mapdecode.NewFieldError("name", errors.New("DNS check requires a name to query"))
+	}
+
+	if dnsConfig.RecordType == "" {
+		dnsConfig.RecordType = "A"
+	}
+	dnsConfig.RecordType = strings.ToUpper(dnsConfig.RecordType)
+	qtype, ok := dnsRecordTypes[dnsConfig.RecordType]
+	if !ok {
+		return mapdecode.NewFieldError("record_type", fmt.Errorf("DNS check does not support record type %s", dnsConfig.RecordType))
+	}
+	dnsConfig.qtype = qtype
+
+	if dnsConfig.Resolver == "" {
+		resolvConf, err := dns.ClientConfigFromFile(defaultResolvConf)
+		if err != nil {
+			return mapdecode.NewFieldError("resolver", fmt.Errorf("no resolver configured and could not read %s: %s", defaultResolvConf, err))
+		}
+		if len(resolvConf.Servers) == 0 {
+			return mapdecode.NewFieldError("resolver", fmt.Errorf("no resolver configured and no nameservers found in %s", defaultResolvConf))
+		}
+		dnsConfig.Resolver = net.JoinHostPort(resolvConf.Servers[0], resolvConf.Port)
+	} else if _, _, err := net.SplitHostPort(dnsConfig.Resolver); err != nil {
+		dnsConfig.Resolver = net.JoinHostPort(dnsConfig.Resolver, defaultDNSPort)
+	}
+
+	if dnsConfig.Protocol == "" {
+		dnsConfig.Protocol = "udp"
+	}
+	if dnsConfig.Protocol != "udp" && dnsConfig.Protocol != "tcp" {
+		return mapdecode.NewFieldError("protocol", fmt.Errorf("DNS check protocol has to be udp or tcp, got %s", dnsConfig.Protocol))
+	}
+
+	if dnsConfig.MinAnswers <= 0 {
+		dnsConfig.MinAnswers = 1
+	}
+
+	check.client = &dns.Client{Net: dnsConfig.Protocol}
+	check.config = dnsConfig
+	return nil
+}
+
+// Run runs the DNS check, it returns the answers (one per line)
+func (check *DNSCheck) Run(ctx context.Context) ([]byte, error) {
+	check.logger.Debug().Msg("running check")
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(check.config.Name), check.config.qtype)
+
+	resp, _, err := check.client.ExchangeContext(ctx, msg, check.config.Resolver)
+	if err != nil {
+		check.logger.Error().Err(err).Msg("check encountered an error")
+		return []byte{}, check.wrapError(err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return []byte{}, check.wrapError(fmt.Errorf("resolver returned %s", dns.RcodeToString[resp.Rcode]))
+	}
+
+	answers := []string{}
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != check.config.qtype {
+			continue
+		}
+		answers = append(answers, formatDNSAnswer(rr))
+	}
+	output := []byte(strings.Join(answers, "\n"))
+
+	if len(answers) < check.config.MinAnswers {
+		return output, check.wrapError(fmt.Errorf("expected at least %d answers, got %d", check.config.MinAnswers, len(answers)))
+	}
+
+	missing := []string{}
+	for _, expected := range check.config.Expected {
+		if !containsDNSAnswer(answers, expected, check.config.qtype) {
+			missing = append(missing, expected)
+		}
+	}
+	if len(missing) > 0 {
+		return output, check.wrapError(fmt.Errorf("expected answers %s not found in [%s]", strings.Join(missing, ", "), strings.Join(answers, ", ")))
+	}
+
+	return output, nil
+}
+
+// formatDNSAnswer returns the textual form of a resource record as
+// documented in `DNSCheckConfig`
+func formatDNSAnswer(rr dns.RR) string {
+	switch r := rr.(type) {
+	case *dns.A:
+		return r.A.String()
+	case *dns.AAAA:
+		return r.AAAA.String()
+	case *dns.CNAME:
+		return normalizeDNSName(r.Target)
+	case *dns.NS:
+		return normalizeDNSName(r.Ns)
+	case *dns.MX:
+		return fmt.Sprintf("%d %s", r.Preference, normalizeDNSName(r.Mx))
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, normalizeDNSName(r.Target))
+	case *dns.TXT:
+		return strings.Join(r.Txt, "")
+	}
+	return rr.String()
+}
+
+// containsDNSAnswer returns true if the expected answer is in the answers.
+// Names are compared case insensitively and regardless of a trailing dot.
+func containsDNSAnswer(answers []string, expected string, qtype uint16) bool {
+	for _, answer := range answers {
+		switch qtype {
+		case dns.TypeTXT:
+			if answer == expected {
+				return true
+			}
+		case dns.TypeA, dns.TypeAAAA:
+			if ip := net.ParseIP(expected); ip != nil && ip.Equal(net.ParseIP(answer)) {
+				return true
+			}
+		default:
+			if answer == normalizeDNSName(expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// Target returns the queried name and record type and the resolver
+func (check *DNSCheck) Target() string {
+	return fmt.Sprintf("%s %s @%s", check.config.RecordType, check.config.Name, check.config.Resolver)
+}
+
+func (check *DNSCheck) wrapError(err error) error {
+	return fmt.Errorf("DNS check failed for %s %s: %w", check.config.RecordType, check.config.Name, err)
+}
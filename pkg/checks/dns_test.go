@@ -0,0 +1,181 @@
+package checks
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var testDNSZone = map[uint16][]string{
+	dns.TypeA:     {"healthy.example.com. 60 IN A 192.0.2.1", "healthy.example.com. 60 IN A 192.0.2.2"},
+	dns.TypeAAAA:  {"healthy.example.com. 60 IN AAAA 2001:db8::1"},
+	dns.TypeCNAME: {"healthy.example.com. 60 IN CNAME lb.example.com."},
+	dns.TypeMX:    {"healthy.example.com. 60 IN MX 10 mx.example.com."},
+	dns.TypeTXT:   {`healthy.example.com. 60 IN TXT "v=spf1 -all"`},
+	dns.TypeSRV:   {"healthy.example.com. 60 IN SRV 10 5 5060 sip.example.com."},
+	dns.TypeNS:    {"healthy.example.com. 60 IN NS ns1.example.com."},
+}
+
+// getDNSServer starts an in-process DNS server that answers queries for healthy.example.com
+// from testDNSZone, fails queries for broken.example.com and returns NXDOMAIN for anything else
+func getDNSServer(t *testing.T) (string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not start DNS server: %v", err)
+	}
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetReply(r)
+		switch r.Question[0].Name {
+		case "healthy.example.com.":
+			for _, record := range testDNSZone[r.Question[0].Qtype] {
+				rr, _ := dns.NewRR(record)
+				m.Answer = append(m.Answer, rr)
+			}
+		case "broken.example.com.":
+			m.Rcode = dns.RcodeServerFailure
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: mux, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	<-started
+	return pc.LocalAddr().String(), func() { srv.Shutdown() }
+}
+
+func TestDNSRun(t *testing.T) {
+	resolver, shutdown := getDNSServer(t)
+	defer shutdown()
+
+	tests := []struct {
+		name            string
+		configInput     map[string]interface{}
+		shouldFailCheck bool
+	}{
+		{name: "A record resolves", configInput: map[string]interface{}{"name": "healthy.example.com"}},
+		{
+			name:        "A record with expected answers",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "expected": []string{"192.0.2.2", "192.0.2.1"}},
+		},
+		{
+			name:            "A record with a missing expected answer",
+			configInput:     map[string]interface{}{"name": "healthy.example.com", "expected": []string{"192.0.2.3"}},
+			shouldFailCheck: true,
+		},
+		{name: "minimum answers", configInput: map[string]interface{}{"name": "healthy.example.com", "min_answers": 2}},
+		{
+			name:            "not enough answers",
+			configInput:     map[string]interface{}{"name": "healthy.example.com", "min_answers": 3},
+			shouldFailCheck: true,
+		},
+		{
+			name:        "AAAA record",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "record_type": "AAAA", "expected": []string{"2001:0db8::0001"}},
+		},
+		{
+			name:        "CNAME record",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "record_type": "cname", "expected": []string{"LB.example.com."}},
+		},
+		{
+			name:        "MX record",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "record_type": "MX", "expected": []string{"10 mx.example.com"}},
+		},
+		{
+			name:        "TXT record",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "record_type": "TXT", "expected": []string{"v=spf1 -all"}},
+		},
+		{
+			name:        "SRV record",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "record_type": "SRV", "expected": []string{"10 5 5060 sip.example.com"}},
+		},
+		{
+			name:        "NS record",
+			configInput: map[string]interface{}{"name": "healthy.example.com", "record_type": "NS", "expected": []string{"ns1.example.com"}},
+		},
+		{
+			name:            "NXDOMAIN",
+			configInput:     map[string]interface{}{"name": "missing.example.com"},
+			shouldFailCheck: true,
+		},
+		{
+			name:            "SERVFAIL",
+			configInput:     map[string]interface{}{"name": "broken.example.com"},
+			shouldFailCheck: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &DNSCheck{}
+			c.Initialize(testCtx)
+			test.configInput["resolver"] = resolver
+			if err := c.Configure(test.configInput); err != nil {
+				t.Fatalf("failed configuring DNS check: %v", err)
+			}
+			_, err := RunWithTimeout(testCtx, c, time.Second)
+			if test.shouldFailCheck && err == nil {
+				t.Fatalf("test should have failed but succeeded")
+			}
+
+			if !test.shouldFailCheck && err != nil {
+				t.Fatalf("failed running DNS check: %v", err)
+			}
+		})
+	}
+}
+
+func TestDNSConfigure(t *testing.T) {
+	tests := []struct {
+		name                string
+		configInput         map[string]interface{}
+		expectedResolver    string
+		shouldFailConfigure bool
+	}{
+		{
+			name:             "resolver without a port",
+			configInput:      map[string]interface{}{"name": "example.com", "resolver": "192.0.2.53"},
+			expectedResolver: "192.0.2.53:53",
+		},
+		{
+			name:             "resolver with a port",
+			configInput:      map[string]interface{}{"name": "example.com", "resolver": "192.0.2.53:5353"},
+			expectedResolver: "192.0.2.53:5353",
+		},
+		{name: "missing name", configInput: map[string]interface{}{"resolver": "192.0.2.53"}, shouldFailConfigure: true},
+		{
+			name:                "unsupported record type",
+			configInput:         map[string]interface{}{"name": "example.com", "resolver": "192.0.2.53", "record_type": "PTR"},
+			shouldFailConfigure: true,
+		},
+		{
+			name:                "unsupported protocol",
+			configInput:         map[string]interface{}{"name": "example.com", "resolver": "192.0.2.53", "protocol": "quic"},
+			shouldFailConfigure: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &DNSCheck{}
+			c.Initialize(testCtx)
+			err := c.Configure(test.configInput)
+			if err != nil && !test.shouldFailConfigure {
+				t.Fatalf("expected configuration to succeed, configuration failed: %v", err)
+			} else if err != nil && test.shouldFailConfigure {
+				return
+			}
+			if test.shouldFailConfigure {
+				t.Fatalf("expected configuration to fail")
+			}
+			if c.config.Resolver != test.expectedResolver {
+				t.Fatalf("expected resolver %s, got %s", test.expectedResolver, c.config.Resolver)
+			}
+		})
+	}
+}
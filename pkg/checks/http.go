@@ -3,6 +3,7 @@ package checks
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -10,14 +11,13 @@ import (
 	"net/url"
 
 	"github.com/amitizle/muffin/internal/logger"
-	"github.com/mitchellh/mapstructure"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/secret"
 	"github.com/rs/zerolog"
 )
 
 // TODO
-// * Use jsonpath notation to verify status based on JSON path in the response (https://github.com/tidwall/gjson)
 // * on all config, use better option method, i.e sending an `Option` type to the new struct creation.
-// * Support configuring headers (such as auth headers)
 
 // HTTPCheck is a struct that defines the HTTP check.
 // It holds the HTTP client and an HTTPCheckConfig struct.
@@ -37,9 +37,63 @@ type HTTPCheckConfig struct {
 	Payload              []byte `mapstructure:"payload"`
 	ErrorHTTPStatusCodes []int  `mapstructure:"error_http_status_codes"`
 
+	// JSONAssertions are evaluated against the response body, each failed
+	// assertion is reported in the check's error
+	JSONAssertions []*JSONAssertion `mapstructure:"json_assertions"`
+
+	// Headers are added to every request, values can be read from the environment
+	// or from files (see `secret.Secret`)
+	Headers     map[string]*secret.Secret `mapstructure:"headers"`
+	BasicAuth   *HTTPBasicAuthConfig      `mapstructure:"basic_auth"`
+	BearerToken *secret.Secret            `mapstructure:"bearer_token"`
+
 	// private fields
 	errorHTTPStatusCodesMap map[int]bool
 	parsedURL               *url.URL
+	headers                 http.Header
+}
+
+// HTTPBasicAuthConfig holds the credentials used for HTTP basic authentication
+type HTTPBasicAuthConfig struct {
+	Username string         `mapstructure:"username"`
+	Password *secret.Secret `mapstructure:"password"`
+}
+
+// buildHeaders resolves the configured headers and authentication secrets
+// into the `http.Header` that will be sent with every request.
+func (checkConfig *HTTPCheckConfig) buildHeaders() error {
+	headers := http.Header{}
+	for name, value := range checkConfig.Headers {
+		v, err := value.Resolve()
+		if err != nil {
+			return mapdecode.NewFieldError(fmt.Sprintf("headers[%s]", name), fmt.Errorf("could not resolve header %s: %s", name, err))
+		}
+		headers.Set(name, v)
+	}
+
+	if checkConfig.BasicAuth != nil && checkConfig.BearerToken.IsSet() {
+		return mapdecode.NewFieldError("bearer_token", errors.New("basic_auth and bearer_token are mutually exclusive"))
+	}
+
+	if checkConfig.BasicAuth != nil {
+		password, err := checkConfig.BasicAuth.Password.Resolve()
+		if err != nil {
+			return mapdecode.NewFieldError("basic_auth.password", fmt.Errorf("could not resolve basic auth password: %s", err))
+		}
+		credentials := checkConfig.BasicAuth.Username + ":" + password
+		headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	if checkConfig.BearerToken.IsSet() {
+		token, err := checkConfig.BearerToken.Resolve()
+		if err != nil {
+			return mapdecode.NewFieldError("bearer_token", fmt.Errorf("could not resolve bearer token: %s", err))
+		}
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	checkConfig.headers = headers
+	return nil
 }
 
 // useDefaultErrorCodes populated an HTTPCheckConfig's HTTP error codes
@@ -72,12 +126,12 @@ func (check *HTTPCheck) Configure(config map[string]interface{}) error {
 	httpConfig := &HTTPCheckConfig{
 		errorHTTPStatusCodesMap: map[int]bool{},
 	}
-	if err := mapstructure.Decode(config, httpConfig); err != nil {
+	if err := mapdecode.Decode(config, httpConfig); err != nil {
 		return err
 	}
 	u, err := url.ParseRequestURI(httpConfig.URL)
 	if err != nil {
-		return err
+		return mapdecode.NewFieldError("url", err)
 	}
 	httpConfig.parsedURL = u
 
@@ -89,8 +143,23 @@ func (check *HTTPCheck) Configure(config map[string]interface{}) error {
 		httpConfig.useDefaultErrorCodes()
 	}
 
+	if err := httpConfig.buildHeaders(); err != nil {
+		return err
+	}
+
+	for i, assertion := range httpConfig.JSONAssertions {
+		if err := assertion.prepare(); err != nil {
+			return mapdecode.NewFieldError(fmt.Sprintf("json_assertions[%d]", i), err)
+		}
+	}
+
 	if httpConfig.Method == "" {
-		httpConfig.Method = http.MethodHead
+		// assertions need a response body, a HEAD request won't return one
+		if len(httpConfig.JSONAssertions) > 0 {
+			httpConfig.Method = http.MethodGet
+		} else {
+			httpConfig.Method = http.MethodHead
+		}
 	}
 
 	if httpConfig.Payload == nil {
@@ -101,14 +170,21 @@ func (check *HTTPCheck) Configure(config map[string]interface{}) error {
 	return nil
 }
 
-// Run runs the HTTP check
-func (check *HTTPCheck) Run() ([]byte, error) {
+// Run runs the HTTP check, the request is cancelled when the given context is done.
+func (check *HTTPCheck) Run(ctx context.Context) ([]byte, error) {
 	check.logger.Debug().Msg("running check")
-	req, err := http.NewRequest(check.config.Method, check.config.parsedURL.String(), bytes.NewBuffer(check.config.Payload))
+	req, err := http.NewRequestWithContext(ctx, check.config.Method, check.config.parsedURL.String(), bytes.NewBuffer(check.config.Payload))
 	if err != nil {
 		check.logger.Error().Err(err).Msg("check encountered an error")
 		return []byte{}, check.wrapError(err)
 	}
+	for name, values := range check.config.headers {
+		req.Header[name] = values
+	}
+	// the Host header is taken from the request itself and not from its headers
+	if host := check.config.headers.Get("Host"); host != "" {
+		req.Host = host
+	}
 
 	resp, err := check.client.Do(req)
 	// if resp is not nil it means that the HTTP request failed, however the
@@ -129,6 +205,10 @@ func (check *HTTPCheck) Run() ([]byte, error) {
 		return body, check.wrapError(errors.New(resp.Status))
 	}
 
+	if err := evaluateJSONAssertions(body, check.config.JSONAssertions); err != nil {
+		return body, check.wrapError(err)
+	}
+
 	return body, nil
 }
 
@@ -137,6 +217,11 @@ func (check *HTTPCheck) GetFullURL() string {
 	return check.config.parsedURL.String()
 }
 
+// Target returns the check's URL
+func (check *HTTPCheck) Target() string {
+	return check.GetFullURL()
+}
+
 func (check *HTTPCheck) wrapError(err error) error {
-	return fmt.Errorf("HTTP check failed to %s: %s", check.config.parsedURL.String(), err)
+	return fmt.Errorf("HTTP check failed to %s: %w", check.config.parsedURL.String(), err)
 }
@@ -3,9 +3,16 @@ package checks
 import "context"
 
 // Check interface is the interface that
-// all checks has to implement
+// all checks has to implement.
+// `Run` should return as soon as the given context is done.
 type Check interface {
 	Initialize(context.Context) error
 	Configure(map[string]interface{}) error
-	Run() ([]byte, error)
+	Run(context.Context) ([]byte, error)
+}
+
+// Targeter is implemented by checks that can describe the resource
+// they're checking, i.e a URL or a host:port address
+type Targeter interface {
+	Target() string
 }
@@ -0,0 +1,150 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Operators supported by a JSONAssertion
+const (
+	OperatorEquals      = "equals"
+	OperatorNotEquals   = "not-equals"
+	OperatorExists      = "exists"
+	OperatorNotExists   = "not-exists"
+	OperatorRegex       = "regex"
+	OperatorGreaterThan = "greater-than"
+	OperatorLessThan    = "less-than"
+)
+
+// JSONAssertion is a single assertion that's evaluated against a JSON document.
+// `Path` uses the gjson path syntax (https://github.com/tidwall/gjson#path-syntax),
+// `Operator` is one of the operators defined in this package and `Value` is the
+// expected value (not used by `exists` and `not-exists`).
+type JSONAssertion struct {
+	Path     string      `mapstructure:"path"`
+	Operator string      `mapstructure:"operator"`
+	Value    interface{} `mapstructure:"value"`
+
+	// private fields
+	regex     *regexp.Regexp
+	threshold float64
+}
+
+// prepare validates the assertion and pre-compiles whatever is needed
+// in order to evaluate it (regular expressions, numeric thresholds).
+func (assertion *JSONAssertion) prepare() error {
+	if assertion.Path == "" {
+		return fmt.Errorf("json assertion is missing a path")
+	}
+	if assertion.Operator == "" {
+		assertion.Operator = OperatorEquals
+	}
+	switch assertion.Operator {
+	case OperatorEquals, OperatorNotEquals, OperatorExists, OperatorNotExists:
+	case OperatorRegex:
+		re, err := regexp.Compile(fmt.Sprint(assertion.Value))
+		if err != nil {
+			return fmt.Errorf("json assertion on %q has an invalid regex: %s", assertion.Path, err)
+		}
+		assertion.regex = re
+	case OperatorGreaterThan, OperatorLessThan:
+		threshold, err := strconv.ParseFloat(fmt.Sprint(assertion.Value), 64)
+		if err != nil {
+			return fmt.Errorf("json assertion on %q expects a numeric value for %s: %s", assertion.Path, assertion.Operator, err)
+		}
+		assertion.threshold = threshold
+	default:
+		return fmt.Errorf("json assertion on %q has an unknown operator %q", assertion.Path, assertion.Operator)
+	}
+	return nil
+}
+
+// evaluate evaluates the assertion against the given JSON document, it returns
+// nil if the assertion holds, otherwise an error describing why it failed.
+func (assertion *JSONAssertion) evaluate(doc []byte) error {
+	result := gjson.GetBytes(doc, assertion.Path)
+	switch assertion.Operator {
+	case OperatorExists:
+		if !result.Exists() {
+			return fmt.Errorf("%q does not exist", assertion.Path)
+		}
+		return nil
+	case OperatorNotExists:
+		if result.Exists() {
+			return fmt.Errorf("%q exists (%s)", assertion.Path, result.Raw)
+		}
+		return nil
+	}
+
+	if !result.Exists() {
+		return fmt.Errorf("%q does not exist", assertion.Path)
+	}
+
+	switch assertion.Operator {
+	case OperatorEquals:
+		if !jsonValueEquals(result, assertion.Value) {
+			return fmt.Errorf("%q expected to equal %v, got %s", assertion.Path, assertion.Value, result.Raw)
+		}
+	case OperatorNotEquals:
+		if jsonValueEquals(result, assertion.Value) {
+			return fmt.Errorf("%q expected to not equal %v", assertion.Path, assertion.Value)
+		}
+	case OperatorRegex:
+		if !assertion.regex.MatchString(result.String()) {
+			return fmt.Errorf("%q expected to match %s, got %s", assertion.Path, assertion.regex, result.Raw)
+		}
+	case OperatorGreaterThan:
+		if result.Type != gjson.Number || result.Num <= assertion.threshold {
+			return fmt.Errorf("%q expected to be greater than %v, got %s", assertion.Path, assertion.threshold, result.Raw)
+		}
+	case OperatorLessThan:
+		if result.Type != gjson.Number || result.Num >= assertion.threshold {
+			return fmt.Errorf("%q expected to be less than %v, got %s", assertion.Path, assertion.threshold, result.Raw)
+		}
+	}
+	return nil
+}
+
+// jsonValueEquals compares a gjson result with a value decoded from the configuration.
+// Numbers are compared numerically, booleans and nulls by their JSON type and anything
+// else by its string representation.
+func jsonValueEquals(result gjson.Result, expected interface{}) bool {
+	switch v := expected.(type) {
+	case nil:
+		return result.Type == gjson.Null
+	case bool:
+		return (result.Type == gjson.True || result.Type == gjson.False) && result.Bool() == v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		n, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil {
+			return false
+		}
+		return result.Type == gjson.Number && result.Num == n
+	}
+	return result.String() == fmt.Sprint(expected)
+}
+
+// evaluateJSONAssertions evaluates all of the given assertions against the JSON document
+// and returns a single error that lists every failed assertion.
+func evaluateJSONAssertions(doc []byte, assertions []*JSONAssertion) error {
+	if len(assertions) == 0 {
+		return nil
+	}
+	if !gjson.ValidBytes(doc) {
+		return fmt.Errorf("json assertions failed: response body is not valid JSON")
+	}
+	failures := []string{}
+	for _, assertion := range assertions {
+		if err := assertion.evaluate(doc); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("json assertions failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultTimeout is the timeout used for checks that
+// don't have a timeout configured
+const DefaultTimeout = 30 * time.Second
+
+var (
+	// ErrTimeout is the error that's returned (wrapped) when a check did not
+	// finish within its timeout, use `errors.Is` to test for it.
+	ErrTimeout = errors.New("check timed out")
+)
+
+type runResult struct {
+	output []byte
+	err    error
+}
+
+// RunWithTimeout runs the check with a context that's cancelled after the given timeout
+// (or `DefaultTimeout` if the timeout is not positive).
+// It returns as soon as the timeout is reached, even if the check itself does not
+// respect its context, in that case the returned error wraps `ErrTimeout`.
+func RunWithTimeout(ctx context.Context, check Check, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan runResult, 1)
+	go func() {
+		output, err := check.Run(runCtx)
+		done <- runResult{output: output, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		if result.err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return result.output, fmt.Errorf("%w after %s: %s", ErrTimeout, timeout, result.err)
+		}
+		return result.output, result.err
+	case <-runCtx.Done():
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return []byte{}, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+		}
+		return []byte{}, runCtx.Err()
+	}
+}
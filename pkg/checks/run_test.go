@@ -0,0 +1,69 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingCheck is a check that blocks until its context is done,
+// or forever if `ignoreContext` is set
+type blockingCheck struct {
+	ignoreContext bool
+}
+
+func (c *blockingCheck) Initialize(context.Context) error       { return nil }
+func (c *blockingCheck) Configure(map[string]interface{}) error { return nil }
+func (c *blockingCheck) Run(ctx context.Context) ([]byte, error) {
+	if c.ignoreContext {
+		select {}
+	}
+	<-ctx.Done()
+	return []byte{}, ctx.Err()
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+	}{
+		{name: "check respecting its context", check: &blockingCheck{}},
+		{name: "check ignoring its context", check: &blockingCheck{ignoreContext: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := RunWithTimeout(testCtx, test.check, 50*time.Millisecond)
+			if !errors.Is(err, ErrTimeout) {
+				t.Fatalf("expected a timeout error, got: %v", err)
+			}
+			if time.Since(start) > time.Second {
+				t.Fatalf("expected check to time out after 50ms, took %s", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestHTTPRunTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c := &HTTPCheck{}
+	c.Initialize(testCtx)
+	if err := c.Configure(map[string]interface{}{"url": srv.URL}); err != nil {
+		t.Fatalf("failed configuring HTTP check: %v", err)
+	}
+	_, err := RunWithTimeout(testCtx, c, 50*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected a timeout error, got: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// defaultTCPMaxReadBytes is the maximum number of bytes that are read
+	// from the connection when an expectation is configured
+	defaultTCPMaxReadBytes = 4096
+)
+
+// TCPCheck is a struct that defines the TCP check.
+// It dials a TCP address and optionally sends a payload and verifies
+// the reply (i.e banner checks).
+type TCPCheck struct {
+	config *TCPCheckConfig
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+// TCPCheckConfig is a struct that holds the configuration required for the TCP check.
+// `Expect` is a string that has to be contained in the reply, `ExpectRegex` is
+// a regular expression that the reply has to match. If none of them is given the check
+// only verifies that a connection could be established.
+type TCPCheckConfig struct {
+	Host         string `mapstructure:"host"`
+	Port         int    `mapstructure:"port"`
+	Payload      string `mapstructure:"payload"`
+	Expect       string `mapstructure:"expect"`
+	ExpectRegex  string `mapstructure:"expect_regex"`
+	MaxReadBytes int    `mapstructure:"max_read_bytes"`
+
+	// private fields
+	address     string
+	expectRegex *regexp.Regexp
+}
+
+// Initialize initializes the TCP check
+func (check *TCPCheck) Initialize(ctx context.Context) error {
+	check.ctx = ctx
+	lg, err := logger.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	check.logger = lg
+	return nil
+}
+
+// Configure decodes map[string]interface{} to a TCPCheckConfig struct instance
+// and verifies it.
+func (check *TCPCheck) Configure(config map[string]interface{}) error {
+	tcpConfig := &TCPCheckConfig{}
+	if err := mapdecode.Decode(config, tcpConfig); err != nil {
+		return err
+	}
+	if tcpConfig.Host == "" {
+		return mapdecode.NewFieldError("host", errors.New("TCP check requires a host"))
+	}
+	if tcpConfig.Port <= 0 || tcpConfig.Port > 65535 {
+		return mapdecode.NewFieldError("port", fmt.Errorf("TCP check has an invalid port %d", tcpConfig.Port))
+	}
+	tcpConfig.address = net.JoinHostPort(tcpConfig.Host, strconv.Itoa(tcpConfig.Port))
+
+	if tcpConfig.Expect != "" && tcpConfig.ExpectRegex != "" {
+		return mapdecode.NewFieldError("expect_regex", errors.New("expect and expect_regex are mutually exclusive"))
+	}
+	if tcpConfig.ExpectRegex != "" {
+		re, err := regexp.Compile(tcpConfig.ExpectRegex)
+		if err != nil {
+			return mapdecode.NewFieldError("expect_regex", err)
+		}
+		tcpConfig.expectRegex = re
+	}
+
+	if tcpConfig.MaxReadBytes <= 0 {
+		tcpConfig.MaxReadBytes = defaultTCPMaxReadBytes
+	}
+
+	check.config = tcpConfig
+	return nil
+}
+
+// Run runs the TCP check, the connection is bounded by the deadline of the given context.
+// It returns whatever was read from the connection.
+func (check *TCPCheck) Run(ctx context.Context) ([]byte, error) {
+	check.logger.Debug().Msg("running check")
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", check.config.address)
+	if err != nil {
+		check.logger.Error().Err(err).Msg("check encountered an error")
+		return []byte{}, check.wrapError(err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return []byte{}, check.wrapError(err)
+		}
+	}
+	// unblock reads and writes in case the context is cancelled before its deadline
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if check.config.Payload != "" {
+		if _, err := conn.Write([]byte(check.config.Payload)); err != nil {
+			return []byte{}, check.wrapError(err)
+		}
+	}
+
+	if !check.expectsReply() {
+		return []byte{}, nil
+	}
+
+	reply, err := check.readReply(conn)
+	if check.matches(reply) {
+		return reply, nil
+	}
+	if err != nil {
+		return reply, check.wrapError(fmt.Errorf("reply did not match before read failed: %w", err))
+	}
+	return reply, check.wrapError(fmt.Errorf("reply did not match, got %q", reply))
+}
+
+// GetAddress returns the host:port address of the check
+func (check *TCPCheck) GetAddress() string {
+	return check.config.address
+}
+
+// Target returns the check's address
+func (check *TCPCheck) Target() string {
+	return check.GetAddress()
+}
+
+func (check *TCPCheck) expectsReply() bool {
+	return check.config.Expect != "" || check.config.expectRegex != nil
+}
+
+// readReply reads from the connection until the reply matches the configured
+// expectation, the connection is closed or `MaxReadBytes` were read.
+func (check *TCPCheck) readReply(conn net.Conn) ([]byte, error) {
+	reply := []byte{}
+	buf := make([]byte, check.config.MaxReadBytes)
+	for len(reply) < check.config.MaxReadBytes {
+		n, err := conn.Read(buf[:check.config.MaxReadBytes-len(reply)])
+		reply = append(reply, buf[:n]...)
+		if check.matches(reply) {
+			return reply, nil
+		}
+		if err != nil {
+			return reply, err
+		}
+	}
+	return reply, nil
+}
+
+func (check *TCPCheck) matches(reply []byte) bool {
+	if check.config.expectRegex != nil {
+		return check.config.expectRegex.Match(reply)
+	}
+	return bytes.Contains(reply, []byte(check.config.Expect))
+}
+
+func (check *TCPCheck) wrapError(err error) error {
+	return fmt.Errorf("TCP check failed to %s: %w", check.config.address, err)
+}
@@ -0,0 +1,140 @@
+package checks
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// getTCPServer starts a TCP server that writes the banner on every new connection
+// and echoes back the first line it reads
+func getTCPServer(t *testing.T, banner string) (string, int, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not start TCP server: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.Write([]byte(banner))
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				conn.Write([]byte("echo: " + line))
+			}(conn)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, func() { ln.Close() }
+}
+
+func TestTCPRun(t *testing.T) {
+	host, port, closeServer := getTCPServer(t, "+OK muffin ready\r\n")
+	defer closeServer()
+
+	tests := []struct {
+		name            string
+		configInput     map[string]interface{}
+		shouldFailCheck bool
+	}{
+		{
+			name:        "connect only",
+			configInput: map[string]interface{}{},
+		},
+		{
+			name:        "banner contains expected string",
+			configInput: map[string]interface{}{"expect": "+OK"},
+		},
+		{
+			name:        "banner matches expected regex",
+			configInput: map[string]interface{}{"expect_regex": `^\+OK \w+ ready`},
+		},
+		{
+			name:        "reply to payload",
+			configInput: map[string]interface{}{"payload": "PING\n", "expect": "echo: PING"},
+		},
+		{
+			name:            "reply does not match",
+			configInput:     map[string]interface{}{"expect": "-ERR"},
+			shouldFailCheck: true,
+		},
+		{
+			name:            "nothing listening on port",
+			configInput:     map[string]interface{}{"port": 1},
+			shouldFailCheck: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &TCPCheck{}
+			c.Initialize(testCtx)
+			test.configInput["host"] = host
+			if _, ok := test.configInput["port"]; !ok {
+				test.configInput["port"] = port
+			}
+			if err := c.Configure(test.configInput); err != nil {
+				t.Fatalf("failed configuring TCP check: %v", err)
+			}
+			_, err := RunWithTimeout(testCtx, c, time.Second)
+			if test.shouldFailCheck && err == nil {
+				t.Fatalf("test should have failed but succeeded")
+			}
+
+			if !test.shouldFailCheck && err != nil {
+				t.Fatalf("failed running TCP check: %v", err)
+			}
+		})
+	}
+}
+
+func TestTCPConfigure(t *testing.T) {
+	tests := []struct {
+		name                string
+		configInput         map[string]interface{}
+		shouldFailConfigure bool
+	}{
+		{name: "host and port", configInput: map[string]interface{}{"host": "localhost", "port": 5432}},
+		{name: "missing host", configInput: map[string]interface{}{"port": 5432}, shouldFailConfigure: true},
+		{name: "missing port", configInput: map[string]interface{}{"host": "localhost"}, shouldFailConfigure: true},
+		{name: "port out of range", configInput: map[string]interface{}{"host": "localhost", "port": 70000}, shouldFailConfigure: true},
+		{name: "invalid regex", configInput: map[string]interface{}{"host": "localhost", "port": 22, "expect_regex": "("}, shouldFailConfigure: true},
+		{
+			name:                "expect and expect_regex",
+			configInput:         map[string]interface{}{"host": "localhost", "port": 22, "expect": "SSH", "expect_regex": "SSH"},
+			shouldFailConfigure: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &TCPCheck{}
+			c.Initialize(testCtx)
+			err := c.Configure(test.configInput)
+			if err != nil && !test.shouldFailConfigure {
+				t.Fatalf("expected configuration to succeed, configuration failed: %v", err)
+			}
+			if err == nil && test.shouldFailConfigure {
+				t.Fatalf("expected configuration to fail")
+			}
+		})
+	}
+}
+
+func TestTCPGetAddress(t *testing.T) {
+	c := &TCPCheck{}
+	c.Initialize(testCtx)
+	c.Configure(map[string]interface{}{"host": "::1", "port": 6379})
+	expected := net.JoinHostPort("::1", strconv.Itoa(6379))
+	if c.GetAddress() != expected {
+		t.Fatalf("expected address %s, got %s", expected, c.GetAddress())
+	}
+}
@@ -0,0 +1,184 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultTLSPort     = 443
+	defaultTLSWarnDays = 14
+)
+
+// TLSCheck is a struct that defines the TLS check.
+// It connects to a TLS endpoint, validates its certificate chain and
+// fails if the leaf certificate is about to expire.
+type TLSCheck struct {
+	config *TLSCheckConfig
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+// TLSCheckConfig is a struct that holds the configuration required for the TLS check.
+// `ServerName` is used for SNI and for verifying the certificate, it defaults to `Host`.
+// `CAFile` is a PEM bundle that's used instead of the system roots.
+type TLSCheckConfig struct {
+	Host       string `mapstructure:"host"`
+	Port       int    `mapstructure:"port"`
+	ServerName string `mapstructure:"server_name"`
+	CAFile     string `mapstructure:"ca_file"`
+	WarnDays   int    `mapstructure:"warn_days"`
+
+	// private fields
+	address string
+	roots   *x509.CertPool
+}
+
+// Initialize initializes the TLS check
+func (check *TLSCheck) Initialize(ctx context.Context) error {
+	check.ctx = ctx
+	lg, err := logger.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	check.logger = lg
+	return nil
+}
+
+// Configure decodes map[string]interface{} to a TLSCheckConfig struct instance.
+// It loads the CA bundle (if configured) and sets default values.
+func (check *TLSCheck) Configure(config map[string]interface{}) error {
+	tlsConfig := &TLSCheckConfig{}
+	if err := mapdecode.Decode(config, tlsConfig); err != nil {
+		return err
+	}
+	if tlsConfig.Host == "" {
+		return mapdecode.NewFieldError("host", errors.New("TLS check requires a host"))
+	}
+	if tlsConfig.Port == 0 {
+		tlsConfig.Port = defaultTLSPort
+	}
+	if tlsConfig.Port < 0 || tlsConfig.Port > 65535 {
+		return mapdecode.NewFieldError("port", fmt.Errorf("TLS check has an invalid port %d", tlsConfig.Port))
+	}
+	tlsConfig.address = net.JoinHostPort(tlsConfig.Host, strconv.Itoa(tlsConfig.Port))
+
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = tlsConfig.Host
+	}
+	if tlsConfig.WarnDays == 0 {
+		tlsConfig.WarnDays = defaultTLSWarnDays
+	}
+
+	if tlsConfig.CAFile != "" {
+		pem, err := ioutil.ReadFile(tlsConfig.CAFile)
+		if err != nil {
+			return mapdecode.NewFieldError("ca_file", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return mapdecode.NewFieldError("ca_file", fmt.Errorf("no certificates found in CA file %s", tlsConfig.CAFile))
+		}
+		tlsConfig.roots = roots
+	}
+
+	check.config = tlsConfig
+	return nil
+}
+
+// Run runs the TLS check, it returns a description of the leaf certificate
+// (subject, issuer, SANs and days remaining).
+func (check *TLSCheck) Run(ctx context.Context) ([]byte, error) {
+	check.logger.Debug().Msg("running check")
+	dialer := &net.Dialer{}
+	rawConn, err := dialer.DialContext(ctx, "tcp", check.config.address)
+	if err != nil {
+		check.logger.Error().Err(err).Msg("check encountered an error")
+		return []byte{}, check.wrapError(err)
+	}
+	defer rawConn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := rawConn.SetDeadline(deadline); err != nil {
+			return []byte{}, check.wrapError(err)
+		}
+	}
+
+	// verification is done after the handshake so an invalid certificate
+	// can still be described in the check's output
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName:         check.config.ServerName,
+		InsecureSkipVerify: true,
+	})
+	if err := conn.Handshake(); err != nil {
+		check.logger.Error().Err(err).Msg("check encountered an error")
+		return []byte{}, check.wrapError(err)
+	}
+
+	peerCerts := conn.ConnectionState().PeerCertificates
+	if len(peerCerts) == 0 {
+		return []byte{}, check.wrapError(errors.New("no certificates presented"))
+	}
+	leaf := peerCerts[0]
+	daysRemaining := int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+	output := []byte(describeCertificate(leaf, daysRemaining))
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range peerCerts[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       check.config.ServerName,
+		Roots:         check.config.roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return output, check.wrapError(err)
+	}
+
+	if daysRemaining < check.config.WarnDays {
+		return output, check.wrapError(fmt.Errorf("certificate expires in %d days (on %s)", daysRemaining, leaf.NotAfter.UTC().Format(time.RFC3339)))
+	}
+
+	return output, nil
+}
+
+// Target returns the check's address and server name
+func (check *TLSCheck) Target() string {
+	if check.config.ServerName == check.config.Host {
+		return check.config.address
+	}
+	return fmt.Sprintf("%s (%s)", check.config.address, check.config.ServerName)
+}
+
+// describeCertificate returns a human readable description of a certificate
+func describeCertificate(cert *x509.Certificate, daysRemaining int) string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return fmt.Sprintf(
+		"subject: %s\nissuer: %s\nSANs: %s\nexpires: %s\ndays remaining: %d",
+		cert.Subject,
+		cert.Issuer,
+		strings.Join(sans, ", "),
+		cert.NotAfter.UTC().Format(time.RFC3339),
+		daysRemaining,
+	)
+}
+
+func (check *TLSCheck) wrapError(err error) error {
+	return fmt.Errorf("TLS check failed to %s (%s): %w", check.config.address, check.config.ServerName, err)
+}
@@ -0,0 +1,111 @@
+package checks
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSRun(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	host, port := addr.IP.String(), addr.Port
+
+	dir, err := ioutil.TempDir("", "muffin-tls")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("could not write CA file: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		configInput     map[string]interface{}
+		shouldFailCheck bool
+	}{
+		{name: "valid chain with CA bundle", configInput: map[string]interface{}{"ca_file": caFile}},
+		{name: "valid chain with SNI", configInput: map[string]interface{}{"ca_file": caFile, "server_name": "example.com"}},
+		{
+			name:            "certificate does not match server name",
+			configInput:     map[string]interface{}{"ca_file": caFile, "server_name": "muffin.invalid"},
+			shouldFailCheck: true,
+		},
+		{
+			name:            "unknown authority with system roots",
+			configInput:     map[string]interface{}{},
+			shouldFailCheck: true,
+		},
+		{
+			name:            "certificate expires within warn days",
+			configInput:     map[string]interface{}{"ca_file": caFile, "warn_days": 365 * 1000},
+			shouldFailCheck: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &TLSCheck{}
+			c.Initialize(testCtx)
+			test.configInput["host"] = host
+			test.configInput["port"] = port
+			if err := c.Configure(test.configInput); err != nil {
+				t.Fatalf("failed configuring TLS check: %v", err)
+			}
+			output, err := RunWithTimeout(testCtx, c, time.Second)
+			if test.shouldFailCheck && err == nil {
+				t.Fatalf("test should have failed but succeeded")
+			}
+
+			if !test.shouldFailCheck && err != nil {
+				t.Fatalf("failed running TLS check: %v", err)
+			}
+
+			for _, field := range []string{"subject:", "issuer:", "SANs: example.com", "days remaining:"} {
+				if !strings.Contains(string(output), field) {
+					t.Errorf("expected output to contain %q, got %q", field, output)
+				}
+			}
+		})
+	}
+}
+
+func TestTLSConfigure(t *testing.T) {
+	c := &TLSCheck{}
+	c.Initialize(testCtx)
+	if err := c.Configure(map[string]interface{}{"host": "example.com"}); err != nil {
+		t.Fatalf("expected configuration to succeed, configuration failed: %v", err)
+	}
+	if c.config.address != "example.com:443" {
+		t.Errorf("expected default port 443, got address %s", c.config.address)
+	}
+	if c.config.ServerName != "example.com" {
+		t.Errorf("expected server name to default to the host, got %s", c.config.ServerName)
+	}
+	if c.config.WarnDays != defaultTLSWarnDays {
+		t.Errorf("expected default warn days %d, got %d", defaultTLSWarnDays, c.config.WarnDays)
+	}
+
+	for _, configInput := range []map[string]interface{}{
+		{},
+		{"host": "example.com", "ca_file": "/non/existing/ca.pem"},
+		{"host": "example.com", "port": 100000},
+	} {
+		c := &TLSCheck{}
+		c.Initialize(testCtx)
+		if err := c.Configure(configInput); err == nil {
+			t.Errorf("expected configuration %v to fail", configInput)
+		}
+	}
+}
@@ -7,6 +7,12 @@ func FromString(checkType string) (Check, error) {
 	switch checkType {
 	case "http":
 		return &HTTPCheck{}, nil
+	case "tcp":
+		return &TCPCheck{}, nil
+	case "dns":
+		return &DNSCheck{}, nil
+	case "tls":
+		return &TLSCheck{}, nil
 	}
 	return nil, fmt.Errorf("no such type: %s", checkType)
 }
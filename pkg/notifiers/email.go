@@ -0,0 +1,365 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/secret"
+	"github.com/rs/zerolog"
+)
+
+// TLS modes of the email notifier
+const (
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "implicit"
+	EmailTLSNone     = "none"
+)
+
+// Authentication mechanisms of the email notifier
+const (
+	EmailAuthPlain = "plain"
+	EmailAuthLogin = "login"
+)
+
+const (
+	defaultEmailTimeout = 30 * time.Second
+	defaultEmailSubject = `[muffin] {{.CheckName}} is {{.State}}`
+	defaultEmailBody    = `{{.Summary}}
+{{if .Error}}
+Error: {{.Error}}{{end}}
+Time: {{.Timestamp.Format "2006-01-02T15:04:05Z07:00"}}
+`
+)
+
+// EmailNotifier holds the configuration for an instance
+// of an email (SMTP) notifier
+type EmailNotifier struct {
+	config *emailNotifierConfig
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+// emailNotifierConfig is the configuration of the email notifier.
+// `Subject` and `Body` are text/templates and `HTMLBody` is an html/template,
+// all of them are executed with an `Event`. If `HTMLBody` is set the email
+// is sent with both a plaintext and an HTML part.
+type emailNotifierConfig struct {
+	Host               string         `mapstructure:"host"`
+	Port               int            `mapstructure:"port"`
+	TLS                string         `mapstructure:"tls"`
+	InsecureSkipVerify bool           `mapstructure:"insecure_skip_verify"`
+	Auth               string         `mapstructure:"auth"`
+	Username           string         `mapstructure:"username"`
+	Password           *secret.Secret `mapstructure:"password"`
+	From               string         `mapstructure:"from"`
+	To                 []string       `mapstructure:"to"`
+	Subject            string         `mapstructure:"subject"`
+	Body               string         `mapstructure:"body"`
+	HTMLBody           string         `mapstructure:"html_body"`
+	Timeout            time.Duration  `mapstructure:"timeout"`
+
+	address  string
+	password string
+	from     *mail.Address
+	to       []*mail.Address
+	subject  *template.Template
+	body     *template.Template
+	htmlBody *htmltemplate.Template
+}
+
+// Initialize initializes the email notifier
+func (notifier *EmailNotifier) Initialize(ctx context.Context) error {
+	notifier.ctx = ctx
+	lg, err := logger.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	notifier.logger = lg
+	notifier.config = &emailNotifierConfig{}
+	return nil
+}
+
+// Configure configures the email notifier, it parses the addresses and templates
+// and resolves the password
+func (notifier *EmailNotifier) Configure(config map[string]interface{}) error {
+	notifierConfig := &emailNotifierConfig{}
+	if err := mapdecode.Decode(config, notifierConfig); err != nil {
+		return err
+	}
+	if notifierConfig.Host == "" {
+		return mapdecode.NewFieldError("host", errors.New("email notifier requires a host"))
+	}
+
+	if notifierConfig.TLS == "" {
+		notifierConfig.TLS = EmailTLSStartTLS
+	}
+	switch notifierConfig.TLS {
+	case EmailTLSStartTLS, EmailTLSNone:
+		if notifierConfig.Port == 0 {
+			notifierConfig.Port = 587
+		}
+	case EmailTLSImplicit:
+		if notifierConfig.Port == 0 {
+			notifierConfig.Port = 465
+		}
+	default:
+		return mapdecode.NewFieldError("tls", fmt.Errorf("email notifier tls has to be one of %s, %s or %s, got %s", EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone, notifierConfig.TLS))
+	}
+	notifierConfig.address = net.JoinHostPort(notifierConfig.Host, strconv.Itoa(notifierConfig.Port))
+
+	if notifierConfig.Username != "" && notifierConfig.Auth == "" {
+		notifierConfig.Auth = EmailAuthPlain
+	}
+	if notifierConfig.Auth != "" && notifierConfig.Auth != EmailAuthPlain && notifierConfig.Auth != EmailAuthLogin {
+		return mapdecode.NewFieldError("auth", fmt.Errorf("email notifier auth has to be %s or %s, got %s", EmailAuthPlain, EmailAuthLogin, notifierConfig.Auth))
+	}
+	password, err := notifierConfig.Password.Resolve()
+	if err != nil {
+		return mapdecode.NewFieldError("password", fmt.Errorf("could not resolve email password: %s", err))
+	}
+	notifierConfig.password = password
+
+	from, err := mail.ParseAddress(notifierConfig.From)
+	if err != nil {
+		return mapdecode.NewFieldError("from", fmt.Errorf("invalid from address %q: %s", notifierConfig.From, err))
+	}
+	notifierConfig.from = from
+	if len(notifierConfig.To) == 0 {
+		return mapdecode.NewFieldError("to", errors.New("email notifier requires at least one recipient"))
+	}
+	for i, to := range notifierConfig.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return mapdecode.NewFieldError(fmt.Sprintf("to[%d]", i), fmt.Errorf("invalid recipient address %q: %s", to, err))
+		}
+		notifierConfig.to = append(notifierConfig.to, addr)
+	}
+
+	if notifierConfig.Subject == "" {
+		notifierConfig.Subject = defaultEmailSubject
+	}
+	if notifierConfig.Body == "" {
+		notifierConfig.Body = defaultEmailBody
+	}
+	if notifierConfig.subject, err = template.New("subject").Parse(notifierConfig.Subject); err != nil {
+		return mapdecode.NewFieldError("subject", fmt.Errorf("could not parse email subject template: %s", err))
+	}
+	if notifierConfig.body, err = template.New("body").Parse(notifierConfig.Body); err != nil {
+		return mapdecode.NewFieldError("body", fmt.Errorf("could not parse email body template: %s", err))
+	}
+	if notifierConfig.HTMLBody != "" {
+		if notifierConfig.htmlBody, err = htmltemplate.New("html_body").Parse(notifierConfig.HTMLBody); err != nil {
+			return mapdecode.NewFieldError("html_body", fmt.Errorf("could not parse email html body template: %s", err))
+		}
+	}
+
+	if notifierConfig.Timeout <= 0 {
+		notifierConfig.Timeout = defaultEmailTimeout
+	}
+
+	notifier.config = notifierConfig
+	return nil
+}
+
+// Notify sends the event by email to all of the recipients
+func (notifier *EmailNotifier) Notify(event Event) error {
+	msg, err := notifier.buildMessage(event)
+	if err != nil {
+		notifier.logger.Error().Err(err).Msg("could not build email")
+		return err
+	}
+	if err := notifier.send(msg); err != nil {
+		notifier.logger.Error().Err(err).Msg("could not send email")
+		return err
+	}
+	return nil
+}
+
+// buildMessage renders the templates and returns the full email message
+// including its headers
+func (notifier *EmailNotifier) buildMessage(event Event) ([]byte, error) {
+	subject := &bytes.Buffer{}
+	if err := notifier.config.subject.Execute(subject, event); err != nil {
+		return nil, err
+	}
+	body := &bytes.Buffer{}
+	if err := notifier.config.body.Execute(body, event); err != nil {
+		return nil, err
+	}
+
+	recipients := []string{}
+	for _, to := range notifier.config.to {
+		recipients = append(recipients, to.String())
+	}
+
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", notifier.config.from)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(msg, "Date: %s\r\n", event.Timestamp.Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+
+	if notifier.config.htmlBody == nil {
+		fmt.Fprintf(msg, "Content-Type: text/plain; charset=utf-8\r\n")
+		fmt.Fprintf(msg, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(msg, body.Bytes()); err != nil {
+			return nil, err
+		}
+		return msg.Bytes(), nil
+	}
+
+	htmlBody := &bytes.Buffer{}
+	if err := notifier.config.htmlBody.Execute(htmlBody, event); err != nil {
+		return nil, err
+	}
+	parts := &bytes.Buffer{}
+	writer := multipart.NewWriter(parts)
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{contentType: "text/plain; charset=utf-8", content: body.Bytes()},
+		{contentType: "text/html; charset=utf-8", content: htmlBody.Bytes()},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	msg.Write(parts.Bytes())
+	return msg.Bytes(), nil
+}
+
+// send delivers the message over SMTP according to the configured TLS mode and authentication
+func (notifier *EmailNotifier) send(msg []byte) error {
+	tlsConfig := &tls.Config{
+		ServerName:         notifier.config.Host,
+		InsecureSkipVerify: notifier.config.InsecureSkipVerify,
+	}
+	dialer := &net.Dialer{Timeout: notifier.config.Timeout}
+	conn, err := dialer.DialContext(notifier.ctx, "tcp", notifier.config.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(notifier.config.Timeout)); err != nil {
+		return err
+	}
+	if notifier.config.TLS == EmailTLSImplicit {
+		// the handshake happens on the first read of the server's greeting, within the deadline
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, notifier.config.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if notifier.config.TLS == EmailTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", notifier.config.address)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	switch notifier.config.Auth {
+	case EmailAuthPlain:
+		err = client.Auth(smtp.PlainAuth("", notifier.config.Username, notifier.config.password, notifier.config.Host))
+	case EmailAuthLogin:
+		err = client.Auth(&loginAuth{username: notifier.config.Username, password: notifier.config.password, host: notifier.config.Host})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := client.Mail(notifier.config.from.Address); err != nil {
+		return err
+	}
+	for _, to := range notifier.config.to {
+		if err := client.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func writeQuotedPrintable(w io.Writer, content []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(content); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// loginAuth implements the LOGIN authentication mechanism which is not
+// implemented by net/smtp. Like smtp.PlainAuth it refuses to send the
+// credentials over an unencrypted connection to anything but localhost.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
@@ -0,0 +1,398 @@
+package notifiers
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSMTPMessage struct {
+	from          string
+	to            []string
+	data          []byte
+	tls           bool
+	authenticated bool
+}
+
+// fakeSMTPServer is a minimal SMTP server that supports STARTTLS, implicit TLS
+// and PLAIN and LOGIN authentication. It records the messages it receives.
+type fakeSMTPServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	username  string
+	password  string
+
+	mu       sync.Mutex
+	messages []fakeSMTPMessage
+}
+
+func newFakeSMTPServer(t *testing.T, implicitTLS bool) *fakeSMTPServer {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t)}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not start SMTP server: %v", err)
+	}
+	if implicitTLS {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	srv := &fakeSMTPServer{ln: ln, tlsConfig: tlsConfig, username: "muffin", password: "s3cr3t"}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.handle(conn, implicitTLS)
+		}
+	}()
+	return srv
+}
+
+func (srv *fakeSMTPServer) port() int {
+	return srv.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (srv *fakeSMTPServer) received() []fakeSMTPMessage {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.messages
+}
+
+func (srv *fakeSMTPServer) handle(conn net.Conn, isTLS bool) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	msg := fakeSMTPMessage{tls: isTLS}
+	tp.PrintfLine("220 localhost ESMTP fake")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			tp.PrintfLine("500 empty command")
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			if !msg.tls {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN LOGIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, srv.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			msg.tls = true
+		case "AUTH":
+			var username, password string
+			if strings.ToUpper(fields[1]) == "PLAIN" {
+				decoded, _ := base64.StdEncoding.DecodeString(fields[2])
+				parts := strings.Split(string(decoded), "\x00")
+				username, password = parts[1], parts[2]
+			} else {
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				line, _ := tp.ReadLine()
+				decoded, _ := base64.StdEncoding.DecodeString(line)
+				username = string(decoded)
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				line, _ = tp.ReadLine()
+				decoded, _ = base64.StdEncoding.DecodeString(line)
+				password = string(decoded)
+			}
+			if username != srv.username || password != srv.password {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			msg.authenticated = true
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(fields[1], "FROM:"), "<>")
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(fields[1], "TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = data
+			srv.mu.Lock()
+			srv.messages = append(srv.messages, msg)
+			srv.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestEmailNotify(t *testing.T) {
+	tests := []struct {
+		name                string
+		implicitTLS         bool
+		configInput         map[string]interface{}
+		expectTLS           bool
+		expectAuthenticated bool
+		shouldFailNotifier  bool
+	}{
+		{
+			name:        "plaintext without authentication",
+			configInput: map[string]interface{}{"tls": "none"},
+		},
+		{
+			name:                "plaintext to localhost with plain authentication",
+			configInput:         map[string]interface{}{"tls": "none", "username": "muffin", "password": "s3cr3t"},
+			expectAuthenticated: true,
+		},
+		{
+			name:                "STARTTLS with login authentication",
+			configInput:         map[string]interface{}{"insecure_skip_verify": true, "auth": "login", "username": "muffin", "password": "s3cr3t"},
+			expectTLS:           true,
+			expectAuthenticated: true,
+		},
+		{
+			name:                "implicit TLS with plain authentication",
+			implicitTLS:         true,
+			configInput:         map[string]interface{}{"tls": "implicit", "insecure_skip_verify": true, "username": "muffin", "password": "s3cr3t"},
+			expectTLS:           true,
+			expectAuthenticated: true,
+		},
+		{
+			name:               "STARTTLS with an untrusted certificate",
+			configInput:        map[string]interface{}{},
+			shouldFailNotifier: true,
+		},
+		{
+			name:               "implicit TLS with an untrusted certificate",
+			implicitTLS:        true,
+			configInput:        map[string]interface{}{"tls": "implicit"},
+			shouldFailNotifier: true,
+		},
+		{
+			name:               "wrong password",
+			configInput:        map[string]interface{}{"tls": "none", "username": "muffin", "password": "wrong"},
+			shouldFailNotifier: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newFakeSMTPServer(t, test.implicitTLS)
+			defer srv.ln.Close()
+
+			notifier := &EmailNotifier{}
+			notifier.Initialize(testCtx)
+			test.configInput["host"] = "127.0.0.1"
+			test.configInput["port"] = srv.port()
+			test.configInput["from"] = "Muffin <muffin@example.com>"
+			test.configInput["to"] = []string{"ops@example.com", "Support <support@example.com>"}
+			if err := notifier.Configure(test.configInput); err != nil {
+				t.Fatalf("failed configuring email notifier: %v", err)
+			}
+			err := notifier.Notify(testEvent)
+			if test.shouldFailNotifier {
+				if err == nil {
+					t.Fatalf("expected notifying to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed notifying: %v", err)
+			}
+
+			messages := srv.received()
+			if len(messages) != 1 {
+				t.Fatalf("expected 1 message, got %d", len(messages))
+			}
+			msg := messages[0]
+			if msg.tls != test.expectTLS {
+				t.Errorf("expected TLS to be %v", test.expectTLS)
+			}
+			if msg.authenticated != test.expectAuthenticated {
+				t.Errorf("expected authenticated to be %v", test.expectAuthenticated)
+			}
+			if msg.from != "muffin@example.com" {
+				t.Errorf("unexpected envelope sender %s", msg.from)
+			}
+			if strings.Join(msg.to, ",") != "ops@example.com,support@example.com" {
+				t.Errorf("unexpected envelope recipients %v", msg.to)
+			}
+		})
+	}
+}
+
+func TestEmailNotifyCanceled(t *testing.T) {
+	for _, mode := range []string{EmailTLSStartTLS, EmailTLSImplicit} {
+		t.Run(mode, func(t *testing.T) {
+			srv := newFakeSMTPServer(t, mode == EmailTLSImplicit)
+			defer srv.ln.Close()
+
+			ctx, cancel := context.WithCancel(testCtx)
+			cancel()
+			notifier := &EmailNotifier{}
+			notifier.Initialize(ctx)
+			err := notifier.Configure(map[string]interface{}{
+				"host":                 "127.0.0.1",
+				"port":                 srv.port(),
+				"tls":                  mode,
+				"insecure_skip_verify": true,
+				"from":                 "muffin@example.com",
+				"to":                   []string{"ops@example.com"},
+			})
+			if err != nil {
+				t.Fatalf("failed configuring email notifier: %v", err)
+			}
+			if err := notifier.Notify(testEvent); err == nil {
+				t.Fatalf("expected notifying with a canceled context to fail")
+			}
+			if len(srv.received()) != 0 {
+				t.Fatalf("expected no messages to be sent")
+			}
+		})
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	srv := newFakeSMTPServer(t, false)
+	defer srv.ln.Close()
+
+	notifier := &EmailNotifier{}
+	notifier.Initialize(testCtx)
+	err := notifier.Configure(map[string]interface{}{
+		"host":      "127.0.0.1",
+		"port":      srv.port(),
+		"tls":       "none",
+		"from":      "muffin@example.com",
+		"to":        []string{"ops@example.com"},
+		"subject":   "{{.CheckName}} – {{.State}}",
+		"body":      "{{.CheckName}} failed: {{.Error}}",
+		"html_body": "<p>{{.CheckName}} failed: <code>{{.Error}}</code></p>",
+	})
+	if err != nil {
+		t.Fatalf("failed configuring email notifier: %v", err)
+	}
+	if err := notifier.Notify(testEvent); err != nil {
+		t.Fatalf("failed notifying: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(srv.received()[0].data))))
+	if err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "payments api – failing" {
+		t.Errorf("unexpected subject %q (%v)", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected a multipart/alternative message, got %s (%v)", mediaType, err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	expectedParts := []struct {
+		contentType string
+		content     string
+	}{
+		{contentType: "text/plain; charset=utf-8", content: fmt.Sprintf("payments api failed: %s", testEvent.Error)},
+		{contentType: "text/html; charset=utf-8", content: "<p>payments api failed: <code>HTTP check failed: &#34;500 Internal Server Error&#34;</code></p>"},
+	}
+	for _, expected := range expectedParts {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("could not read message part: %v", err)
+		}
+		if part.Header.Get("Content-Type") != expected.contentType {
+			t.Errorf("expected part content type %s, got %s", expected.contentType, part.Header.Get("Content-Type"))
+		}
+		// multipart.Reader decodes quoted-printable parts transparently
+		content, _ := ioutil.ReadAll(part)
+		if string(content) != expected.content {
+			t.Errorf("expected part content %q, got %q", expected.content, content)
+		}
+	}
+}
+
+func TestEmailConfigure(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"host": "smtp.example.com", "from": "muffin@example.com", "to": []string{"ops@example.com"}}
+	}
+	notifier := &EmailNotifier{}
+	notifier.Initialize(testCtx)
+	if err := notifier.Configure(valid()); err != nil {
+		t.Fatalf("expected configuration to succeed, configuration failed: %v", err)
+	}
+	if notifier.config.address != "smtp.example.com:587" {
+		t.Errorf("expected default STARTTLS port 587, got %s", notifier.config.address)
+	}
+
+	invalid := []map[string]interface{}{}
+	for key, value := range map[string]interface{}{
+		"host":      "",
+		"from":      "not an address",
+		"to":        []string{},
+		"tls":       "ssl",
+		"auth":      "cram-md5",
+		"subject":   "{{.CheckName",
+		"html_body": "{{.CheckName",
+	} {
+		configInput := valid()
+		configInput[key] = value
+		invalid = append(invalid, configInput)
+	}
+	for _, configInput := range invalid {
+		notifier := &EmailNotifier{}
+		notifier.Initialize(testCtx)
+		if err := notifier.Configure(configInput); err == nil {
+			t.Errorf("expected configuration %v to fail", configInput)
+		}
+	}
+}
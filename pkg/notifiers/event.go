@@ -0,0 +1,100 @@
+package notifiers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+// Event describes a change in a check's state, it's the
+// structured input all notifiers are notified with
+type Event struct {
+	CheckName string
+	CheckType string
+	// Target is the resource the check is checking (i.e a URL), it can be empty
+	Target   string
+	Labels   map[string]string
+	Severity string
+
+	State         state.State
+	PreviousState state.State
+
+	// Error is the error of the run that triggered the event, it's empty
+	// on recovery. TimedOut is set if that run did not finish in time.
+	Error    string
+	TimedOut bool
+	// Latency is how long the run that triggered the event took
+	Latency time.Duration
+	// Output is an excerpt of the output of the run that triggered the
+	// event (i.e the HTTP response body)
+	Output string
+
+	// Timestamp is the time of the run that triggered the event,
+	// Since is the time in which the check entered its new state
+	Timestamp time.Time
+	Since     time.Time
+	// Duration is how long the check has been down, for a failure it's the time
+	// since the first failed run and for a recovery it's the length of the outage
+	Duration time.Duration
+
+	// Test is set on synthetic events that are sent to verify that notifiers
+	// work (see `TestEvents`), no check actually changed its state
+	Test bool
+}
+
+// TestCheckName is the check name of test events
+const TestCheckName = "muffin-test-notification"
+
+// TestEvents returns a synthetic failure of a test check and its recovery, they're
+// sent to verify that notifiers are configured correctly (i.e by `muffin notify test`).
+// Sending the recovery as well resolves the failure in notifiers that open incidents.
+func TestEvents(now time.Time) []Event {
+	failure := Event{
+		CheckName:     TestCheckName,
+		CheckType:     "test",
+		Labels:        map[string]string{"test": "true"},
+		Severity:      "info",
+		State:         state.Failing,
+		PreviousState: state.OK,
+		Error:         "this is a test notification sent by muffin, no check is failing",
+		Timestamp:     now,
+		Since:         now,
+		Test:          true,
+	}
+	recovery := failure
+	recovery.State, recovery.PreviousState = state.OK, state.Failing
+	recovery.Error = ""
+	return []Event{failure, recovery}
+}
+
+// IsRecovery returns true if the event is a recovery of a failing check
+func (event Event) IsRecovery() bool {
+	return event.PreviousState == state.Failing && event.State == state.OK
+}
+
+// Summary returns a single line, human readable, description of the event,
+// test events are prefixed with [test]
+func (event Event) Summary() string {
+	if event.Test {
+		return "[test] " + event.summary()
+	}
+	return event.summary()
+}
+
+func (event Event) summary() string {
+	switch {
+	case event.State == state.Failing && event.TimedOut:
+		return fmt.Sprintf("check %s is down for %s (since %s), timed out: %s", event.CheckName, event.Duration.Round(time.Second), event.Since.Format(time.RFC3339), event.Error)
+	case event.State == state.Failing:
+		return fmt.Sprintf("check %s is down for %s (since %s): %s", event.CheckName, event.Duration.Round(time.Second), event.Since.Format(time.RFC3339), event.Error)
+	case event.IsRecovery():
+		return fmt.Sprintf("check %s recovered after being down for %s", event.CheckName, event.Duration.Round(time.Second))
+	}
+	return fmt.Sprintf("check %s is %s", event.CheckName, event.State)
+}
+
+// String returns the event's summary
+func (event Event) String() string {
+	return event.Summary()
+}
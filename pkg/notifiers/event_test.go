@@ -0,0 +1,66 @@
+package notifiers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+// recordingNotifier is a `MessageNotifier` that records the messages it's notified with
+type recordingNotifier struct {
+	messages []string
+}
+
+func (n *recordingNotifier) Initialize(context.Context) error       { return nil }
+func (n *recordingNotifier) Configure(map[string]interface{}) error { return nil }
+func (n *recordingNotifier) Notify(msg string) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestEventSummary(t *testing.T) {
+	recovery := Event{CheckName: "payments api", State: state.OK, PreviousState: state.Failing, Duration: 150 * time.Second}
+	timeout := testEvent
+	timeout.TimedOut = true
+	test := TestEvents(testEvent.Timestamp)
+
+	tests := []struct {
+		name     string
+		event    Event
+		contains []string
+	}{
+		{name: "failure", event: testEvent, contains: []string{"payments api is down for 1m30s", "since 2020-01-01T11:58:30Z", testEvent.Error}},
+		{name: "timeout", event: timeout, contains: []string{"payments api is down", "timed out"}},
+		{name: "recovery", event: recovery, contains: []string{"payments api recovered after being down for 2m30s"}},
+		{name: "test failure", event: test[0], contains: []string{"[test] check " + TestCheckName + " is down", "test notification"}},
+		{name: "test recovery", event: test[1], contains: []string{"[test] check " + TestCheckName + " recovered"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary := test.event.Summary()
+			for _, s := range test.contains {
+				if !strings.Contains(summary, s) {
+					t.Errorf("expected summary %q to contain %q", summary, s)
+				}
+			}
+		})
+	}
+}
+
+func TestAdaptMessageNotifier(t *testing.T) {
+	recorder := &recordingNotifier{}
+	var notifier Notifier = AdaptMessageNotifier(recorder)
+	if err := notifier.Initialize(testCtx); err != nil {
+		t.Fatalf("unexpected error initializing adapted notifier: %v", err)
+	}
+	if err := notifier.Notify(testEvent); err != nil {
+		t.Fatalf("unexpected error notifying adapted notifier: %v", err)
+	}
+	if len(recorder.messages) != 1 || recorder.messages[0] != testEvent.Summary() {
+		t.Fatalf("expected the adapted notifier to be notified with the event's summary, got %v", recorder.messages)
+	}
+}
@@ -5,7 +5,31 @@ import "context"
 // Notifier interface is the interface that
 // all notifiers has to implement
 type Notifier interface {
+	Initialize(context.Context) error
+	Configure(map[string]interface{}) error
+	Notify(Event) error
+}
+
+// MessageNotifier is the interface of notifiers that can only send a
+// preformatted message, use `AdaptMessageNotifier` to use them as a `Notifier`
+type MessageNotifier interface {
 	Initialize(context.Context) error
 	Configure(map[string]interface{}) error
 	Notify(string) error
 }
+
+// messageNotifierAdapter adapts a `MessageNotifier` to a `Notifier`
+type messageNotifierAdapter struct {
+	MessageNotifier
+}
+
+// AdaptMessageNotifier returns a `Notifier` that notifies the given
+// `MessageNotifier` with the event's summary
+func AdaptMessageNotifier(notifier MessageNotifier) Notifier {
+	return &messageNotifierAdapter{MessageNotifier: notifier}
+}
+
+// Notify notifies the adapted notifier with the event's summary
+func (adapter *messageNotifierAdapter) Notify(event Event) error {
+	return adapter.MessageNotifier.Notify(event.Summary())
+}
@@ -0,0 +1,206 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/secret"
+	"github.com/amitizle/muffin/internal/truncate"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultPagerDutyTimeout   = 10 * time.Second
+	defaultPagerDutySeverity  = "critical"
+	defaultPagerDutyDedupKey  = "muffin"
+	// pagerDutyMaxSummary is the maximum length of an event's summary
+	pagerDutyMaxSummary = 1024
+)
+
+// pagerDutySeverities are the severities accepted by the Events API
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// PagerDutyNotifier holds the configuration for an instance
+// of a PagerDuty (Events API v2) notifier
+type PagerDutyNotifier struct {
+	client *http.Client
+	config *pagerDutyNotifierConfig
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+// pagerDutyNotifierConfig is the configuration of the PagerDuty notifier.
+// If `Severity` is not configured the check's severity is used when it's a valid
+// PagerDuty severity, otherwise events are sent as critical.
+// `DedupKeyPrefix` is prepended to the check name to form the dedup key, so
+// a recovery resolves the incident that was triggered by the failure.
+type pagerDutyNotifierConfig struct {
+	RoutingKey     *secret.Secret `mapstructure:"routing_key"`
+	EventsURL      string         `mapstructure:"events_url"`
+	Severity       string         `mapstructure:"severity"`
+	Source         string         `mapstructure:"source"`
+	DedupKeyPrefix string         `mapstructure:"dedup_key_prefix"`
+	Timeout        time.Duration  `mapstructure:"timeout"`
+
+	routingKey string
+}
+
+// pagerDutyEvent is the request body of the Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	Class         string                 `json:"class,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// Initialize initializes the PagerDuty notifier
+func (notifier *PagerDutyNotifier) Initialize(ctx context.Context) error {
+	notifier.ctx = ctx
+	lg, err := logger.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	notifier.logger = lg
+	notifier.config = &pagerDutyNotifierConfig{}
+	return nil
+}
+
+// Configure configures the PagerDuty notifier, it resolves the routing key and
+// sets default values
+func (notifier *PagerDutyNotifier) Configure(config map[string]interface{}) error {
+	notifierConfig := &pagerDutyNotifierConfig{}
+	if err := mapdecode.Decode(config, notifierConfig); err != nil {
+		return err
+	}
+	routingKey, err := notifierConfig.RoutingKey.Resolve()
+	if err != nil {
+		return mapdecode.NewFieldError("routing_key", fmt.Errorf("could not resolve routing key: %s", err))
+	}
+	if routingKey == "" {
+		return mapdecode.NewFieldError("routing_key", errors.New("pagerduty notifier requires a routing_key"))
+	}
+	notifierConfig.routingKey = routingKey
+
+	if notifierConfig.EventsURL == "" {
+		notifierConfig.EventsURL = defaultPagerDutyEventsURL
+	}
+	if _, err := url.ParseRequestURI(notifierConfig.EventsURL); err != nil {
+		return mapdecode.NewFieldError("events_url", err)
+	}
+	if notifierConfig.Severity != "" && !pagerDutySeverities[notifierConfig.Severity] {
+		return mapdecode.NewFieldError("severity", fmt.Errorf("pagerduty severity has to be one of critical, error, warning or info, got %s", notifierConfig.Severity))
+	}
+	if notifierConfig.Source == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "muffin"
+		}
+		notifierConfig.Source = hostname
+	}
+	if notifierConfig.DedupKeyPrefix == "" {
+		notifierConfig.DedupKeyPrefix = defaultPagerDutyDedupKey
+	}
+	if notifierConfig.Timeout <= 0 {
+		notifierConfig.Timeout = defaultPagerDutyTimeout
+	}
+
+	notifier.client = &http.Client{Timeout: notifierConfig.Timeout}
+	notifier.config = notifierConfig
+	return nil
+}
+
+// Notify triggers an incident when a check fails and resolves it
+// when the check recovers
+func (notifier *PagerDutyNotifier) Notify(event Event) error {
+	pdEvent := &pagerDutyEvent{
+		RoutingKey: notifier.config.routingKey,
+		DedupKey:   notifier.dedupKey(event.CheckName),
+	}
+	if event.State == state.OK {
+		pdEvent.EventAction = "resolve"
+		return notifier.send(pdEvent)
+	}
+
+	pdEvent.EventAction = "trigger"
+	pdEvent.Payload = &pagerDutyPayload{
+		Summary:   truncate.String(event.Summary(), pagerDutyMaxSummary),
+		Source:    notifier.config.Source,
+		Severity:  notifier.severity(event),
+		Timestamp: event.Timestamp.Format(time.RFC3339),
+		Component: event.CheckName,
+		Class:     event.CheckType,
+		CustomDetails: map[string]interface{}{
+			"error":     event.Error,
+			"timed_out": event.TimedOut,
+			"down_for":  event.Duration.String(),
+			"latency":   event.Latency.String(),
+			"labels":    event.Labels,
+			"output":    event.Output,
+		},
+	}
+	return notifier.send(pdEvent)
+}
+
+// severity returns the PagerDuty severity of an event
+func (notifier *PagerDutyNotifier) severity(event Event) string {
+	if notifier.config.Severity != "" {
+		return notifier.config.Severity
+	}
+	if pagerDutySeverities[event.Severity] {
+		return event.Severity
+	}
+	return defaultPagerDutySeverity
+}
+
+// dedupKey returns the stable dedup key of a check
+func (notifier *PagerDutyNotifier) dedupKey(checkName string) string {
+	return truncate.String(fmt.Sprintf("%s/%s", notifier.config.DedupKeyPrefix, checkName), 255)
+}
+
+func (notifier *PagerDutyNotifier) send(event *pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(notifier.ctx, http.MethodPost, notifier.config.EventsURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifier.client.Do(req)
+	if err != nil {
+		notifier.logger.Error().Err(err).Msg("could not send event to pagerduty")
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("pagerduty returned %s: %s", resp.Status, respBody)
+		notifier.logger.Error().Err(err).Str("event_action", event.EventAction).Msg("could not send event to pagerduty")
+		return err
+	}
+	return nil
+}
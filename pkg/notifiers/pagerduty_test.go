@@ -0,0 +1,132 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/pkg/state"
+)
+
+// getPagerDutyServer starts a stand-in for the PagerDuty events endpoint
+// that records the events it receives
+func getPagerDutyServer(statusCode int) (*httptest.Server, func() []pagerDutyEvent) {
+	var mu sync.Mutex
+	events := []pagerDutyEvent{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := pagerDutyEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+		w.WriteHeader(statusCode)
+		w.Write([]byte(`{"status":"success","message":"Event processed"}`))
+	}))
+	return srv, func() []pagerDutyEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return events
+	}
+}
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	srv, events := getPagerDutyServer(http.StatusAccepted)
+	defer srv.Close()
+
+	notifier := &PagerDutyNotifier{}
+	notifier.Initialize(testCtx)
+	err := notifier.Configure(map[string]interface{}{
+		"routing_key": "R0UT1NGK3Y",
+		"events_url":  srv.URL,
+		"severity":    "error",
+		"source":      "muffin-test",
+	})
+	if err != nil {
+		t.Fatalf("failed configuring pagerduty notifier: %v", err)
+	}
+
+	if err := notifier.Notify(testEvent); err != nil {
+		t.Fatalf("failed triggering: %v", err)
+	}
+	recovery := Event{CheckName: testEvent.CheckName, State: state.OK, PreviousState: state.Failing, Duration: 5 * time.Minute, Timestamp: time.Now()}
+	if err := notifier.Notify(recovery); err != nil {
+		t.Fatalf("failed resolving: %v", err)
+	}
+
+	received := events()
+	if len(received) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(received))
+	}
+	trigger, resolve := received[0], received[1]
+	if trigger.EventAction != "trigger" || resolve.EventAction != "resolve" {
+		t.Fatalf("expected a trigger and a resolve event, got %s and %s", trigger.EventAction, resolve.EventAction)
+	}
+	if trigger.DedupKey == "" || trigger.DedupKey != resolve.DedupKey {
+		t.Fatalf("expected trigger and resolve to share a dedup key, got %q and %q", trigger.DedupKey, resolve.DedupKey)
+	}
+	if trigger.RoutingKey != "R0UT1NGK3Y" {
+		t.Errorf("unexpected routing key %s", trigger.RoutingKey)
+	}
+	if trigger.Payload == nil || trigger.Payload.Severity != "error" || trigger.Payload.Source != "muffin-test" || trigger.Payload.Summary != testEvent.Summary() {
+		t.Errorf("unexpected trigger payload %+v", trigger.Payload)
+	}
+	if resolve.Payload != nil {
+		t.Errorf("expected resolve event without a payload, got %+v", resolve.Payload)
+	}
+
+	other := testEvent
+	other.CheckName = "staging api"
+	notifier.Notify(other)
+	if events()[2].DedupKey == trigger.DedupKey {
+		t.Errorf("expected different checks to have different dedup keys")
+	}
+}
+
+func TestPagerDutyErrorResponse(t *testing.T) {
+	srv, _ := getPagerDutyServer(http.StatusBadRequest)
+	defer srv.Close()
+
+	notifier := &PagerDutyNotifier{}
+	notifier.Initialize(testCtx)
+	if err := notifier.Configure(map[string]interface{}{"routing_key": "R0UT1NGK3Y", "events_url": srv.URL}); err != nil {
+		t.Fatalf("failed configuring pagerduty notifier: %v", err)
+	}
+	if err := notifier.Notify(testEvent); err == nil {
+		t.Fatalf("expected notifying to fail")
+	}
+}
+
+func TestPagerDutyConfigure(t *testing.T) {
+	notifier := &PagerDutyNotifier{}
+	notifier.Initialize(testCtx)
+	if err := notifier.Configure(map[string]interface{}{"routing_key": "R0UT1NGK3Y"}); err != nil {
+		t.Fatalf("expected configuration to succeed, configuration failed: %v", err)
+	}
+	if notifier.config.EventsURL != defaultPagerDutyEventsURL {
+		t.Errorf("expected default events url, got %s", notifier.config.EventsURL)
+	}
+	for eventSeverity, expected := range map[string]string{"warning": "warning", "": "critical", "minor": "critical"} {
+		if severity := notifier.severity(Event{Severity: eventSeverity}); severity != expected {
+			t.Errorf("expected check severity %q to be sent as %q, got %q", eventSeverity, expected, severity)
+		}
+	}
+
+	for _, configInput := range []map[string]interface{}{
+		{},
+		{"routing_key": map[string]interface{}{"env": "MUFFIN_TEST_ROUTING_KEY_MISSING"}},
+		{"routing_key": "R0UT1NGK3Y", "severity": "sev1"},
+		{"routing_key": "R0UT1NGK3Y", "events_url": "not a url"},
+	} {
+		notifier := &PagerDutyNotifier{}
+		notifier.Initialize(testCtx)
+		if err := notifier.Configure(configInput); err == nil {
+			t.Errorf("expected configuration %v to fail", configInput)
+		}
+	}
+}
@@ -1,15 +1,49 @@
 package notifiers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/amitizle/muffin/internal/logger"
-	"github.com/mitchellh/mapstructure"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/offline"
+	"github.com/amitizle/muffin/internal/secret"
+	"github.com/amitizle/muffin/pkg/state"
 	"github.com/nlopes/slack"
 	"github.com/rs/zerolog"
 )
 
+// How recoveries are posted by the slack notifier
+const (
+	// SlackRecoveryThread posts the recovery as a thread reply to the alert
+	SlackRecoveryThread = "thread"
+	// SlackRecoveryUpdate edits the original alert
+	SlackRecoveryUpdate = "update"
+	// SlackRecoveryNew posts the recovery as a new message
+	SlackRecoveryNew = "new"
+)
+
+const (
+	slackColorFailing = "danger"
+	slackColorOK      = "good"
+
+	slackConversationsPageSize = 1000
+)
+
+var slackWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
 // SlackNotifier holds the configuration for an instance
 // of slack notifier
 type SlackNotifier struct {
@@ -17,17 +51,39 @@ type SlackNotifier struct {
 	config *slackNotifierConfig
 	ctx    context.Context
 	logger zerolog.Logger
+
+	// alerts holds the last alert that was posted for every failing check,
+	// so its recovery can be posted in the alert's thread or update it
+	alertsMu sync.Mutex
+	alerts   map[string]slackMessage
 }
 
+// slackNotifierConfig is the configuration of the slack notifier.
+// The notifier either posts with a bot `Token` to a channel given by `ChannelID` or
+// `ChannelName`, or posts to an incoming webhook (`WebhookURL`), in which case
+// recoveries are always posted as new messages.
 type slackNotifierConfig struct {
-	Token       string `mapstructure:"token"`
-	ChannelName string `mapstructure:"channel_name"`
-	channelID   string
+	Token       *secret.Secret `mapstructure:"token"`
+	WebhookURL  *secret.Secret `mapstructure:"webhook_url"`
+	ChannelID   string         `mapstructure:"channel_id"`
+	ChannelName string         `mapstructure:"channel_name"`
+	Recovery    string         `mapstructure:"recovery"`
+	APIURL      string         `mapstructure:"api_url"`
+
+	channelID  string
+	webhookURL string
+}
+
+// slackMessage identifies a message that was posted to slack
+type slackMessage struct {
+	channelID string
+	timestamp string
 }
 
 // Initialize initializes the slack notifier
 func (notifier *SlackNotifier) Initialize(ctx context.Context) error {
 	notifier.ctx = ctx
+	notifier.alerts = map[string]slackMessage{}
 	lg, err := logger.GetContext(ctx)
 	if err != nil {
 		return err
@@ -37,51 +93,240 @@ func (notifier *SlackNotifier) Initialize(ctx context.Context) error {
 	return nil
 }
 
-// Configure configures the slack notifier, it creates a new slack client and finds
-// the channel ID by the configured channel name.
-// It also verifies that the auth token is correct
+// Configure configures the slack notifier.
+// In webhook mode it only validates the webhook URL. Otherwise it creates a new slack
+// client, verifies that the token is correct and finds the channel ID by the
+// configured channel name, unless a channel ID is configured. When the notifier was
+// initialized with an offline context the token and channel are not verified.
 func (notifier *SlackNotifier) Configure(config map[string]interface{}) error {
 	notifierConfig := &slackNotifierConfig{}
-	if err := mapstructure.Decode(config, notifierConfig); err != nil {
+	if err := mapdecode.Decode(config, notifierConfig); err != nil {
 		return err
 	}
-	notifier.client = slack.New(notifierConfig.Token)
+	switch notifierConfig.Recovery {
+	case "":
+		notifierConfig.Recovery = SlackRecoveryThread
+	case SlackRecoveryThread, SlackRecoveryUpdate, SlackRecoveryNew:
+	default:
+		return mapdecode.NewFieldError("recovery", fmt.Errorf("slack recovery has to be one of %s, %s or %s, got %s", SlackRecoveryThread, SlackRecoveryUpdate, SlackRecoveryNew, notifierConfig.Recovery))
+	}
+
+	if notifierConfig.WebhookURL.IsSet() {
+		if notifierConfig.Token.IsSet() {
+			return mapdecode.NewFieldError("webhook_url", errors.New("slack notifier can be configured with either a token or a webhook_url, not both"))
+		}
+		webhookURL, err := notifierConfig.WebhookURL.Resolve()
+		if err != nil {
+			return mapdecode.NewFieldError("webhook_url", fmt.Errorf("could not resolve slack webhook_url: %s", err))
+		}
+		if _, err := url.ParseRequestURI(webhookURL); err != nil {
+			return mapdecode.NewFieldError("webhook_url", errors.New("slack webhook_url is not a valid URL"))
+		}
+		notifierConfig.webhookURL = webhookURL
+		notifier.config = notifierConfig
+		return nil
+	}
+
+	token, err := notifierConfig.Token.Resolve()
+	if err != nil {
+		return mapdecode.NewFieldError("token", fmt.Errorf("could not resolve slack token: %s", err))
+	}
+	if token == "" {
+		return mapdecode.NewFieldError("token", errors.New("slack notifier requires either a token or a webhook_url"))
+	}
+	options := []slack.Option{}
+	if notifierConfig.APIURL != "" {
+		options = append(options, slack.OptionAPIURL(strings.TrimSuffix(notifierConfig.APIURL, "/")+"/"))
+	}
+	notifier.client = slack.New(token, options...)
+	if offline.IsOffline(notifier.ctx) {
+		if notifierConfig.ChannelID == "" && notifierConfig.ChannelName == "" {
+			return mapdecode.NewFieldError("channel_id", errors.New("slack notifier requires either a channel_id or a channel_name"))
+		}
+		notifierConfig.channelID = notifierConfig.ChannelID
+		notifier.config = notifierConfig
+		return nil
+	}
 	authResponse, err := notifier.client.AuthTest()
 	if err != nil {
-		return err
+		notifier.logger.Error().Err(err).Msg("could not authenticate token")
+		return fmt.Errorf("slack token authentication failed (%s), verify that the token is a valid bot token", err)
 	}
 	notifier.logger.Info().Msgf("authenticated as %s (team: %s)", authResponse.User, authResponse.Team)
 
-	channels, err := notifier.client.GetChannels(true)
-	if err != nil {
-		notifier.logger.Error().Err(err).Msg("could not authenticate token")
-		return err
+	switch {
+	case notifierConfig.ChannelID != "":
+		notifierConfig.channelID = notifierConfig.ChannelID
+	case notifierConfig.ChannelName != "":
+		channelID, err := notifier.findChannelID(strings.TrimPrefix(notifierConfig.ChannelName, "#"))
+		if err != nil {
+			notifier.logger.Error().Err(err).Msgf("could not find channel ID for channel %s", notifierConfig.ChannelName)
+			return err
+		}
+		notifierConfig.channelID = channelID
+	default:
+		return mapdecode.NewFieldError("channel_id", errors.New("slack notifier requires either a channel_id or a channel_name"))
 	}
 
-	var foundChannel bool
-	for _, c := range channels {
-		if c.NameNormalized == notifierConfig.ChannelName {
-			notifierConfig.channelID = c.ID
-			foundChannel = true
+	notifier.config = notifierConfig
+	return nil
+}
+
+// findChannelID looks up the ID of a public or private channel by its name,
+// going over all of the pages of channels that are visible to the token
+func (notifier *SlackNotifier) findChannelID(name string) (string, error) {
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: "true",
+		Limit:           slackConversationsPageSize,
+		Types:           []string{"public_channel", "private_channel"},
+	}
+	visible := 0
+	for {
+		channels, cursor, err := notifier.client.GetConversations(params)
+		if err != nil {
+			var rateLimitedErr *slack.RateLimitedError
+			switch {
+			case errors.As(err, &rateLimitedErr):
+				return "", fmt.Errorf("could not list slack channels to find %s: rate limited by slack, retry after %s or set channel_id instead of channel_name", name, rateLimitedErr.RetryAfter)
+			case err.Error() == "missing_scope":
+				return "", fmt.Errorf("could not list slack channels to find %s: the token is missing the channels:read (and groups:read for private channels) scope, add the scopes or set channel_id instead of channel_name", name)
+			}
+			return "", fmt.Errorf("could not list slack channels to find %s: %s", name, err)
+		}
+		for _, c := range channels {
+			if c.NameNormalized == name || c.Name == name {
+				return c.ID, nil
+			}
+		}
+		visible += len(channels)
+		if cursor == "" {
 			break
 		}
+		params.Cursor = cursor
 	}
+	return "", fmt.Errorf("could not find slack channel %s among the %d channels visible to the token, private channels are only visible after inviting the bot to them, or set channel_id instead of channel_name", name, visible)
+}
+
+// Notify posts the event to the slack channel as a colored attachment.
+// Recoveries of alerts that were posted by this notifier are posted according to
+// the configured recovery mode.
+func (notifier *SlackNotifier) Notify(event Event) error {
+	attachment := slackAttachment(event)
 
-	if !foundChannel {
-		notifier.logger.Error().Err(err).Msgf("could not find channel ID for channel %s", notifierConfig.ChannelName)
-		return fmt.Errorf("could not find channel ID for channel %s", notifierConfig.ChannelName)
+	notifier.alertsMu.Lock()
+	alert, hasAlert := notifier.alerts[event.CheckName]
+	notifier.alertsMu.Unlock()
+
+	if notifier.config.webhookURL != "" {
+		if err := notifier.postWebhook(attachment); err != nil {
+			notifier.logger.Error().Err(err).Msg("could not send message to slack")
+			return err
+		}
+		return nil
 	}
 
-	notifier.config = notifierConfig
+	var err error
+	switch {
+	case event.IsRecovery() && hasAlert && notifier.config.Recovery == SlackRecoveryUpdate:
+		_, _, _, err = notifier.client.UpdateMessage(alert.channelID, alert.timestamp, slack.MsgOptionAttachments(attachment))
+	case event.IsRecovery() && hasAlert && notifier.config.Recovery == SlackRecoveryThread:
+		_, _, err = notifier.client.PostMessage(alert.channelID, slack.MsgOptionAttachments(attachment), slack.MsgOptionTS(alert.timestamp))
+	default:
+		var channelID, timestamp string
+		channelID, timestamp, err = notifier.client.PostMessage(notifier.config.channelID, slack.MsgOptionAttachments(attachment))
+		if err == nil && event.State == state.Failing {
+			notifier.alertsMu.Lock()
+			notifier.alerts[event.CheckName] = slackMessage{channelID: channelID, timestamp: timestamp}
+			notifier.alertsMu.Unlock()
+		}
+	}
+	if err != nil {
+		notifier.logger.Error().Err(err).Msg("could not send message to slack")
+		return err
+	}
+	// the alert is kept until its recovery is posted, so a failed recovery
+	// is retried in the alert's thread
+	if event.State != state.Failing && hasAlert {
+		notifier.alertsMu.Lock()
+		if notifier.alerts[event.CheckName] == alert {
+			delete(notifier.alerts, event.CheckName)
+		}
+		notifier.alertsMu.Unlock()
+	}
 	return nil
 }
 
-// Notify notifies tha slack channel with the given message
-func (notifier *SlackNotifier) Notify(msg string) error {
-	_, _, err := notifier.client.PostMessage(notifier.config.channelID, slack.MsgOptionText(msg, false))
+// postWebhook posts the attachment to the incoming webhook
+func (notifier *SlackNotifier) postWebhook(attachment slack.Attachment) error {
+	body, err := json.Marshal(&slack.WebhookMessage{Attachments: []slack.Attachment{attachment}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(notifier.ctx, http.MethodPost, notifier.config.webhookURL, bytes.NewReader(body))
 	if err != nil {
-		notifier.logger.Error().Err(err).Msg("could not send message to slack")
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := slackWebhookClient.Do(req)
+	if err != nil {
+		// the URL is left out of the error as it is a secret
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("slack webhook request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook returned %s: %s", resp.Status, respBody)
+	}
 	return nil
 }
+
+// slackAttachment returns the attachment that describes the event,
+// red for failures and green for recoveries
+func slackAttachment(event Event) slack.Attachment {
+	attachment := slack.Attachment{
+		Fallback: event.Summary(),
+		Color:    slackColorOK,
+		Title:    fmt.Sprintf("%s recovered", event.CheckName),
+		Ts:       json.Number(strconv.FormatInt(event.Timestamp.Unix(), 10)),
+		Footer:   "muffin",
+	}
+	fields := []slack.AttachmentField{
+		{Title: "Check", Value: event.CheckName, Short: true},
+		{Title: "Type", Value: event.CheckType, Short: true},
+	}
+	if event.Target != "" {
+		fields = append(fields, slack.AttachmentField{Title: "Target", Value: event.Target})
+	}
+
+	if event.State == state.Failing {
+		attachment.Color = slackColorFailing
+		attachment.Title = fmt.Sprintf("%s is down", event.CheckName)
+		if event.TimedOut {
+			attachment.Title = fmt.Sprintf("%s is down (timed out)", event.CheckName)
+		}
+		fields = append(fields,
+			slack.AttachmentField{Title: "Down for", Value: event.Duration.Round(time.Second).String(), Short: true},
+			slack.AttachmentField{Title: "Severity", Value: event.Severity, Short: true},
+			slack.AttachmentField{Title: "Error", Value: event.Error},
+		)
+	} else {
+		fields = append(fields, slack.AttachmentField{Title: "Was down for", Value: event.Duration.Round(time.Second).String(), Short: true})
+	}
+	if event.Test {
+		attachment.Title = "[test] " + attachment.Title
+	}
+
+	if len(event.Labels) > 0 {
+		labels := []string{}
+		for k, v := range event.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(labels)
+		fields = append(fields, slack.AttachmentField{Title: "Labels", Value: strings.Join(labels, ", ")})
+	}
+	attachment.Fields = fields
+	return attachment
+}
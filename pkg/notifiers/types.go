@@ -7,6 +7,12 @@ func FromString(checkType string) (Notifier, error) {
 	switch checkType {
 	case "slack":
 		return &SlackNotifier{}, nil
+	case "webhook":
+		return &WebhookNotifier{}, nil
+	case "email":
+		return &EmailNotifier{}, nil
+	case "pagerduty":
+		return &PagerDutyNotifier{}, nil
 	}
 	return nil, fmt.Errorf("no such type: %s", checkType)
 }
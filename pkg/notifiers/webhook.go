@@ -0,0 +1,211 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/internal/mapdecode"
+	"github.com/amitizle/muffin/internal/secret"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	// defaultWebhookBody is the body that's sent if no body template is configured
+	defaultWebhookBody = `{"check": {{json .CheckName}}, "state": {{json .State}}, "error": {{json .Error}}, ` +
+		`"duration_seconds": {{.Duration.Seconds}}, "timestamp": {{json .Timestamp}}, "summary": {{json .Summary}}}`
+)
+
+// webhookTemplateFuncs are the functions available in a webhook body template
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes a value as JSON, i.e a string with quotes and escaping
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// WebhookNotifier holds the configuration for an instance
+// of a generic webhook notifier
+type WebhookNotifier struct {
+	client *http.Client
+	config *webhookNotifierConfig
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+// webhookNotifierConfig is the configuration of the webhook notifier.
+// `Body` is a text/template that's executed with an `Event`.
+type webhookNotifierConfig struct {
+	URL                *secret.Secret            `mapstructure:"url"`
+	Method             string                    `mapstructure:"method"`
+	Headers            map[string]*secret.Secret `mapstructure:"headers"`
+	Body               string                    `mapstructure:"body"`
+	Timeout            time.Duration             `mapstructure:"timeout"`
+	SuccessStatusCodes []int                     `mapstructure:"success_status_codes"`
+	Retry              webhookRetryConfig        `mapstructure:"retry"`
+
+	url                   string
+	headers               http.Header
+	body                  *template.Template
+	successStatusCodesMap map[int]bool
+}
+
+// webhookRetryConfig is the retry policy of the webhook notifier, the backoff
+// between attempts is doubled after every failed attempt up to `MaxBackoff`
+type webhookRetryConfig struct {
+	Attempts   int           `mapstructure:"attempts"`
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// Initialize initializes the webhook notifier
+func (notifier *WebhookNotifier) Initialize(ctx context.Context) error {
+	notifier.ctx = ctx
+	lg, err := logger.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	notifier.logger = lg
+	notifier.config = &webhookNotifierConfig{}
+	return nil
+}
+
+// Configure configures the webhook notifier, it parses the body template,
+// resolves the secrets and sets default values
+func (notifier *WebhookNotifier) Configure(config map[string]interface{}) error {
+	notifierConfig := &webhookNotifierConfig{}
+	if err := mapdecode.Decode(config, notifierConfig); err != nil {
+		return err
+	}
+
+	rawURL, err := notifierConfig.URL.Resolve()
+	if err != nil {
+		return mapdecode.NewFieldError("url", fmt.Errorf("could not resolve webhook url: %s", err))
+	}
+	if rawURL == "" {
+		return mapdecode.NewFieldError("url", errors.New("webhook notifier requires a url"))
+	}
+	if _, err := url.ParseRequestURI(rawURL); err != nil {
+		return mapdecode.NewFieldError("url", err)
+	}
+	notifierConfig.url = rawURL
+
+	notifierConfig.headers = http.Header{}
+	for name, value := range notifierConfig.Headers {
+		v, err := value.Resolve()
+		if err != nil {
+			return mapdecode.NewFieldError(fmt.Sprintf("headers[%s]", name), fmt.Errorf("could not resolve header %s: %s", name, err))
+		}
+		notifierConfig.headers.Set(name, v)
+	}
+	if notifierConfig.headers.Get("Content-Type") == "" {
+		notifierConfig.headers.Set("Content-Type", "application/json")
+	}
+
+	if notifierConfig.Method == "" {
+		notifierConfig.Method = http.MethodPost
+	}
+	if notifierConfig.Body == "" {
+		notifierConfig.Body = defaultWebhookBody
+	}
+	body, err := template.New("body").Funcs(webhookTemplateFuncs).Parse(notifierConfig.Body)
+	if err != nil {
+		return mapdecode.NewFieldError("body", fmt.Errorf("could not parse webhook body template: %s", err))
+	}
+	notifierConfig.body = body
+
+	notifierConfig.successStatusCodesMap = map[int]bool{}
+	if len(notifierConfig.SuccessStatusCodes) == 0 {
+		for i := 200; i < 300; i++ {
+			notifierConfig.successStatusCodesMap[i] = true
+		}
+	}
+	for _, statusCode := range notifierConfig.SuccessStatusCodes {
+		notifierConfig.successStatusCodesMap[statusCode] = true
+	}
+
+	if notifierConfig.Timeout <= 0 {
+		notifierConfig.Timeout = defaultWebhookTimeout
+	}
+	if notifierConfig.Retry.Attempts < 1 {
+		notifierConfig.Retry.Attempts = 1
+	}
+	if notifierConfig.Retry.Backoff <= 0 {
+		notifierConfig.Retry.Backoff = time.Second
+	}
+	if notifierConfig.Retry.MaxBackoff < notifierConfig.Retry.Backoff {
+		notifierConfig.Retry.MaxBackoff = 30 * time.Second
+	}
+
+	notifier.client = &http.Client{Timeout: notifierConfig.Timeout}
+	notifier.config = notifierConfig
+	return nil
+}
+
+// Notify sends the event to the webhook according to the configured retry policy
+func (notifier *WebhookNotifier) Notify(event Event) error {
+	body := &bytes.Buffer{}
+	if err := notifier.config.body.Execute(body, event); err != nil {
+		notifier.logger.Error().Err(err).Msg("could not render webhook body")
+		return err
+	}
+
+	backoff := notifier.config.Retry.Backoff
+	var err error
+	for attempt := 1; attempt <= notifier.config.Retry.Attempts; attempt++ {
+		if err = notifier.send(body.Bytes()); err == nil {
+			return nil
+		}
+		notifier.logger.Warn().Err(err).Int("attempt", attempt).Msg("could not send webhook")
+		if attempt == notifier.config.Retry.Attempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-notifier.ctx.Done():
+			return notifier.ctx.Err()
+		}
+		backoff *= 2
+		if backoff > notifier.config.Retry.MaxBackoff {
+			backoff = notifier.config.Retry.MaxBackoff
+		}
+	}
+	notifier.logger.Error().Err(err).Msg("could not send webhook")
+	return err
+}
+
+// send makes a single webhook request
+func (notifier *WebhookNotifier) send(body []byte) error {
+	req, err := http.NewRequestWithContext(notifier.ctx, notifier.config.Method, notifier.config.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range notifier.config.headers {
+		req.Header[name] = values
+	}
+	resp, err := notifier.client.Do(req)
+	if err != nil {
+		// the URL is left out of the error as it might contain secrets
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("webhook request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if !notifier.config.successStatusCodesMap[resp.StatusCode] {
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amitizle/muffin/internal/logger"
+	"github.com/amitizle/muffin/pkg/state"
+	"github.com/rs/zerolog"
+)
+
+var (
+	testCtx = logger.StoreContext(context.Background(), zerolog.Nop())
+
+	testEvent = Event{
+		CheckName:     "payments api",
+		CheckType:     "http",
+		Severity:      "critical",
+		State:         state.Failing,
+		PreviousState: state.OK,
+		Error:         `HTTP check failed: "500 Internal Server Error"`,
+		Duration:      90 * time.Second,
+		Timestamp:     time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC),
+		Since:         time.Date(2020, 1, 1, 11, 58, 30, 0, time.UTC),
+	}
+)
+
+type webhookRequest struct {
+	method  string
+	headers http.Header
+	body    []byte
+}
+
+// getWebhookServer starts a server that records the requests it receives and responds
+// with the given status codes in order (the last one is repeated)
+func getWebhookServer(statusCodes ...int) (*httptest.Server, func() []webhookRequest) {
+	var mu sync.Mutex
+	requests := []webhookRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, webhookRequest{method: r.Method, headers: r.Header, body: body})
+		statusCode := statusCodes[len(statusCodes)-1]
+		if len(requests) <= len(statusCodes) {
+			statusCode = statusCodes[len(requests)-1]
+		}
+		w.WriteHeader(statusCode)
+	}))
+	return srv, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	srv, requests := getWebhookServer(http.StatusOK)
+	defer srv.Close()
+
+	notifier := &WebhookNotifier{}
+	notifier.Initialize(testCtx)
+	if err := notifier.Configure(map[string]interface{}{"url": srv.URL}); err != nil {
+		t.Fatalf("failed configuring webhook notifier: %v", err)
+	}
+	if err := notifier.Notify(testEvent); err != nil {
+		t.Fatalf("failed notifying: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	if reqs[0].method != http.MethodPost {
+		t.Errorf("expected default method POST, got %s", reqs[0].method)
+	}
+	if reqs[0].headers.Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON content type, got %s", reqs[0].headers.Get("Content-Type"))
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(reqs[0].body, &body); err != nil {
+		t.Fatalf("expected default body to be valid JSON, got %s: %v", reqs[0].body, err)
+	}
+	if body["check"] != testEvent.CheckName || body["state"] != "failing" || body["error"] != testEvent.Error || body["duration_seconds"] != 90.0 {
+		t.Errorf("unexpected body %s", reqs[0].body)
+	}
+}
+
+func TestWebhookCustomRequest(t *testing.T) {
+	srv, requests := getWebhookServer(http.StatusAccepted)
+	defer srv.Close()
+
+	notifier := &WebhookNotifier{}
+	notifier.Initialize(testCtx)
+	err := notifier.Configure(map[string]interface{}{
+		"url":     srv.URL,
+		"method":  "PUT",
+		"headers": map[string]interface{}{"Content-Type": "text/plain", "X-Token": "abc"},
+		"body":    `{{.CheckName}} is {{.State}} since {{.Timestamp.Format "15:04"}}`,
+	})
+	if err != nil {
+		t.Fatalf("failed configuring webhook notifier: %v", err)
+	}
+	if err := notifier.Notify(testEvent); err != nil {
+		t.Fatalf("failed notifying: %v", err)
+	}
+
+	req := requests()[0]
+	if req.method != http.MethodPut {
+		t.Errorf("expected method PUT, got %s", req.method)
+	}
+	if req.headers.Get("X-Token") != "abc" || req.headers.Get("Content-Type") != "text/plain" {
+		t.Errorf("expected configured headers, got %v", req.headers)
+	}
+	if string(req.body) != "payments api is failing since 12:00" {
+		t.Errorf("unexpected body %q", req.body)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name               string
+		statusCodes        []int
+		configInput        map[string]interface{}
+		expectedRequests   int
+		shouldFailNotifier bool
+	}{
+		{
+			name:             "succeeds after retries",
+			statusCodes:      []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			configInput:      map[string]interface{}{"retry": map[string]interface{}{"attempts": 3, "backoff": "1ms"}},
+			expectedRequests: 3,
+		},
+		{
+			name:               "gives up after all attempts",
+			statusCodes:        []int{http.StatusInternalServerError},
+			configInput:        map[string]interface{}{"retry": map[string]interface{}{"attempts": 2, "backoff": "1ms"}},
+			expectedRequests:   2,
+			shouldFailNotifier: true,
+		},
+		{
+			name:               "no retries by default",
+			statusCodes:        []int{http.StatusInternalServerError},
+			configInput:        map[string]interface{}{},
+			expectedRequests:   1,
+			shouldFailNotifier: true,
+		},
+		{
+			name:             "custom success status codes",
+			statusCodes:      []int{http.StatusFound},
+			configInput:      map[string]interface{}{"success_status_codes": []int{302}},
+			expectedRequests: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, requests := getWebhookServer(test.statusCodes...)
+			defer srv.Close()
+
+			notifier := &WebhookNotifier{}
+			notifier.Initialize(testCtx)
+			test.configInput["url"] = srv.URL
+			if err := notifier.Configure(test.configInput); err != nil {
+				t.Fatalf("failed configuring webhook notifier: %v", err)
+			}
+			err := notifier.Notify(testEvent)
+			if test.shouldFailNotifier && err == nil {
+				t.Fatalf("expected notifying to fail")
+			}
+			if !test.shouldFailNotifier && err != nil {
+				t.Fatalf("failed notifying: %v", err)
+			}
+			if len(requests()) != test.expectedRequests {
+				t.Fatalf("expected %d requests, got %d", test.expectedRequests, len(requests()))
+			}
+		})
+	}
+}
+
+func TestWebhookConfigure(t *testing.T) {
+	for _, configInput := range []map[string]interface{}{
+		{},
+		{"url": "not a url"},
+		{"url": "http://localhost", "body": "{{.CheckName"},
+		{"url": map[string]interface{}{"env": "MUFFIN_TEST_WEBHOOK_URL_MISSING"}},
+	} {
+		notifier := &WebhookNotifier{}
+		notifier.Initialize(testCtx)
+		if err := notifier.Configure(configInput); err == nil {
+			t.Errorf("expected configuration %v to fail", configInput)
+		}
+	}
+}
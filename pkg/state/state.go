@@ -0,0 +1,203 @@
+package state
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of a check
+type State int
+
+const (
+	// Unknown is the state of a check that did not run yet
+	Unknown State = iota
+	// OK is the state of a check whose last run succeeded
+	OK
+	// Failing is the state of a check whose last run failed
+	Failing
+)
+
+// String returns the string representation of a state
+func (s State) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Failing:
+		return "failing"
+	}
+	return "unknown"
+}
+
+// MarshalText returns the string representation of a state,
+// so states are encoded as strings (i.e in JSON)
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses the string representation of a state,
+// unknown values are parsed as `Unknown`
+func (s *State) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "ok":
+		*s = OK
+	case "failing":
+		*s = Failing
+	default:
+		*s = Unknown
+	}
+	return nil
+}
+
+// Transition describes a change in a check's state
+type Transition struct {
+	From State
+	To   State
+	// At is the time of the run that confirmed the transition
+	At time.Time
+	// Since is the time of the first run of the streak that led to the transition,
+	// it's earlier than `At` when a threshold greater than 1 is used
+	Since time.Time
+	// Duration is how long the check was in the `From` state,
+	// it's zero when transitioning from `Unknown`
+	Duration time.Duration
+}
+
+// IsRecovery returns true if the transition is from a failing state to an OK state
+func (t *Transition) IsRecovery() bool {
+	return t.From == Failing && t.To == OK
+}
+
+// IsFailure returns true if the transition is into a failing state
+func (t *Transition) IsFailure() bool {
+	return t.To == Failing
+}
+
+// Tracker tracks the state of a single check across runs.
+// A check only transitions to `Failing` after `failureThreshold` consecutive failed
+// runs and to `OK` after `successThreshold` consecutive successful runs.
+// It is safe for concurrent use.
+type Tracker struct {
+	mu               sync.Mutex
+	state            State
+	since            time.Time
+	failureThreshold int
+	successThreshold int
+
+	// the current streak of consecutive results that are different than the current
+	// state, streakState is the state the streak counts towards. While the state is
+	// `Unknown` both successes and failures are streaks, so it's needed to tell them apart.
+	streak      int
+	streakSince time.Time
+	streakState State
+}
+
+// NewTracker returns a new tracker in the `Unknown` state.
+// Thresholds lower than 1 are treated as 1.
+func NewTracker(failureThreshold, successThreshold int) *Tracker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+	return &Tracker{
+		state:            Unknown,
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+	}
+}
+
+// Update records the result of a check's run at the given time.
+// It returns the transition and true if the state of the check changed.
+func (t *Tracker) Update(success bool, at time.Time) (*Transition, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next, threshold := Failing, t.failureThreshold
+	if success {
+		next, threshold = OK, t.successThreshold
+	}
+	if next == t.state {
+		t.streak = 0
+		return nil, false
+	}
+
+	if t.streak == 0 || t.streakState != next {
+		t.streak = 0
+		t.streakSince = at
+		t.streakState = next
+	}
+	t.streak++
+	if t.streak < threshold {
+		return nil, false
+	}
+
+	transition := &Transition{From: t.state, To: next, At: at, Since: t.streakSince}
+	if t.state != Unknown {
+		transition.Duration = t.streakSince.Sub(t.since)
+	}
+	t.state = next
+	t.since = t.streakSince
+	t.streak = 0
+	return transition, true
+}
+
+// State returns the current state
+func (t *Tracker) State() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// Since returns the time of the first run of the streak that
+// moved the tracker into its current state
+func (t *Tracker) Since() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.since
+}
+
+// Snapshot is the state of a tracker, it's used to persist a
+// tracker and restore it (i.e after a restart)
+type Snapshot struct {
+	State       State
+	Since       time.Time
+	Streak      int
+	StreakSince time.Time
+	StreakState State
+}
+
+// Snapshot returns a snapshot of the tracker's state
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Snapshot{
+		State:       t.state,
+		Since:       t.since,
+		Streak:      t.streak,
+		StreakSince: t.streakSince,
+		StreakState: t.streakState,
+	}
+}
+
+// Restore restores the tracker's state from a snapshot,
+// the tracker's thresholds are not changed
+func (t *Tracker) Restore(s Snapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = s.State
+	t.since = s.Since
+	t.streak = s.Streak
+	t.streakSince = s.StreakSince
+	t.streakState = s.StreakState
+	// snapshots that were persisted before the streak's state was kept can
+	// only have a streak towards the opposite of a known state
+	if t.streakState == Unknown {
+		switch t.state {
+		case OK:
+			t.streakState = Failing
+		case Failing:
+			t.streakState = OK
+		}
+	}
+}
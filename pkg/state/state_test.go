@@ -0,0 +1,182 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackerTransitions(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		results        []bool
+		expectedStates []State
+		// expectedChanges holds the indexes of the results that should
+		// trigger a transition
+		expectedChanges map[int]bool
+	}{
+		{
+			name:            "first success is a transition from unknown",
+			results:         []bool{true, true, true},
+			expectedStates:  []State{OK, OK, OK},
+			expectedChanges: map[int]bool{0: true},
+		},
+		{
+			name:            "repeated failures only transition once",
+			results:         []bool{true, false, false, false},
+			expectedStates:  []State{OK, Failing, Failing, Failing},
+			expectedChanges: map[int]bool{0: true, 1: true},
+		},
+		{
+			name:            "failure and recovery",
+			results:         []bool{false, false, true, true, false},
+			expectedStates:  []State{Failing, Failing, OK, OK, Failing},
+			expectedChanges: map[int]bool{0: true, 2: true, 4: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := NewTracker(1, 1)
+			if tracker.State() != Unknown {
+				t.Fatalf("expected new tracker to be in unknown state, got %s", tracker.State())
+			}
+			for i, result := range test.results {
+				_, changed := tracker.Update(result, start.Add(time.Duration(i)*time.Minute))
+				if changed != test.expectedChanges[i] {
+					t.Fatalf("run %d: expected changed to be %v, got %v", i, test.expectedChanges[i], changed)
+				}
+				if tracker.State() != test.expectedStates[i] {
+					t.Fatalf("run %d: expected state %s, got %s", i, test.expectedStates[i], tracker.State())
+				}
+			}
+		})
+	}
+}
+
+func TestTrackerRecoveryDuration(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(1, 1)
+	tracker.Update(true, start)
+
+	down, _ := tracker.Update(false, start.Add(time.Minute))
+	if !down.IsFailure() || down.IsRecovery() {
+		t.Fatalf("expected a failure transition, got %+v", down)
+	}
+	tracker.Update(false, start.Add(2*time.Minute))
+
+	up, changed := tracker.Update(true, start.Add(6*time.Minute))
+	if !changed || !up.IsRecovery() {
+		t.Fatalf("expected a recovery transition, got %+v", up)
+	}
+	if up.Duration != 5*time.Minute {
+		t.Fatalf("expected check to be down for 5m, got %s", up.Duration)
+	}
+}
+
+func TestTrackerThresholds(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(3, 2)
+	results := []struct {
+		success       bool
+		expectedState State
+		changed       bool
+	}{
+		{success: false, expectedState: Unknown},
+		{success: false, expectedState: Unknown},
+		{success: false, expectedState: Failing, changed: true},
+		{success: true, expectedState: Failing},
+		// a single failure resets the success streak
+		{success: false, expectedState: Failing},
+		{success: true, expectedState: Failing},
+		{success: true, expectedState: OK, changed: true},
+		{success: false, expectedState: OK},
+		{success: false, expectedState: OK},
+		{success: true, expectedState: OK},
+		{success: false, expectedState: OK},
+	}
+
+	var transitions []*Transition
+	for i, result := range results {
+		transition, changed := tracker.Update(result.success, start.Add(time.Duration(i)*time.Minute))
+		if changed != result.changed {
+			t.Fatalf("run %d: expected changed to be %v, got %v", i, result.changed, changed)
+		}
+		if tracker.State() != result.expectedState {
+			t.Fatalf("run %d: expected state %s, got %s", i, result.expectedState, tracker.State())
+		}
+		if changed {
+			transitions = append(transitions, transition)
+		}
+	}
+
+	down, up := transitions[0], transitions[1]
+	if !down.Since.Equal(start) || !down.At.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("expected failure streak to start at the first failed run, got since %s at %s", down.Since, down.At)
+	}
+	// down from the first failure (minute 0) until the first success of the recovering streak (minute 5)
+	if up.Duration != 5*time.Minute {
+		t.Errorf("expected check to be down for 5m, got %s", up.Duration)
+	}
+}
+
+func TestTrackerUnknownStreaks(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(3, 3)
+	results := []struct {
+		success       bool
+		expectedState State
+		changed       bool
+	}{
+		{success: true, expectedState: Unknown},
+		{success: true, expectedState: Unknown},
+		// a failure does not continue the streak of successes
+		{success: false, expectedState: Unknown},
+		{success: false, expectedState: Unknown},
+		{success: false, expectedState: Failing, changed: true},
+	}
+
+	var transition *Transition
+	for i, result := range results {
+		var changed bool
+		transition, changed = tracker.Update(result.success, start.Add(time.Duration(i)*time.Minute))
+		if changed != result.changed {
+			t.Fatalf("run %d: expected changed to be %v, got %v", i, result.changed, changed)
+		}
+		if tracker.State() != result.expectedState {
+			t.Fatalf("run %d: expected state %s, got %s", i, result.expectedState, tracker.State())
+		}
+	}
+	if !transition.Since.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("expected failure streak to start at the first failed run, got %s", transition.Since)
+	}
+}
+
+func TestStateText(t *testing.T) {
+	for _, s := range []State{Unknown, OK, Failing} {
+		text, _ := s.MarshalText()
+		var parsed State
+		if err := parsed.UnmarshalText(text); err != nil || parsed != s {
+			t.Errorf("expected %q to be parsed as %s, got %s (%v)", text, s, parsed, err)
+		}
+	}
+}
+
+func TestTrackerRestore(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(3, 1)
+	tracker.Update(true, start)
+	tracker.Update(false, start.Add(time.Minute))
+	tracker.Update(false, start.Add(2*time.Minute))
+
+	restored := NewTracker(3, 1)
+	restored.Restore(tracker.Snapshot())
+	if restored.State() != OK || !restored.Since().Equal(start) {
+		t.Fatalf("expected restored tracker to be ok since %s, got %s since %s", start, restored.State(), restored.Since())
+	}
+	// the restored streak of failures continues, so a single failure is enough to reach the threshold
+	transition, changed := restored.Update(false, start.Add(3*time.Minute))
+	if !changed || transition.To != Failing || !transition.Since.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected the restored failure streak to continue, got %+v", transition)
+	}
+}